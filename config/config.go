@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,8 +22,46 @@ type Config struct {
 	APITitle         string
 	APIDesc          string
 	APIVersion       string
+
+	// Default visibility of emotes by moderation state
+	ShowDeletedEmotes  bool
+	ShowPrivateEmotes  bool
+	ShowUnlistedEmotes bool
+	ShowPendingEmotes  bool
+
+	// Storage folders that can be browsed through the storage API
+	StorageFolders []string
+
+	// Largest image (in bytes) downloaded from 7TV for mirroring
+	MaxImageSize int64
+
+	// First frame previews and thumbnails (THUMBNAIL_SIZE pixels square) of
+	// mirrored images
+	GeneratePreviews bool
+	ThumbnailSize    int
+
+	// Image endpoint: "redirect" to the blob or "proxy" the bytes
+	ImageDeliveryMode string
+
+	// Public base URL of stored blobs (e.g. a CDN in front of the container),
+	// per-folder overrides, and the webhook called to purge the paths of an
+	// emote whose image changed
+	PublicBaseURL  string
+	PublicBaseURLs map[string]string
+	CDNPurgeURL    string
+	CDNPurgeToken  string
+
+	// Signed URLs for private containers: "" (public container), "blob" or
+	// "account", with the lifetime and permissions of the SAS tokens
+	SASMode        string
+	SASExpiry      time.Duration
+	SASPermissions string
 }
 
+// maxThumbnailSize caps THUMBNAIL_SIZE, like the largest size of the resize
+// endpoint, since a thumbnail canvas is allocated for every mirrored image.
+const maxThumbnailSize = 1024
+
 func getEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -30,10 +69,68 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getMapEnv parses a comma separated list of key=value pairs, such as
+// "trending_emotes=https://cdn.example.com/trending". Trailing slashes are
+// trimmed from the values.
+func getMapEnv(key string) map[string]string {
+	values := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || k == "" || v == "" {
+			continue
+		}
+		values[k] = strings.TrimSuffix(v, "/")
+	}
+	return values
+}
+
+func getBoolEnvWithDefault(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getInt64EnvWithDefault parses a positive integer variable, falling back to
+// the default (with a warning) when it is set but isn't a positive integer.
+func getInt64EnvWithDefault(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed <= 0 {
+		log.Printf("⚠️  Invalid %s %q (must be a positive integer), using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getListEnvWithDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func LoadConfig() *Config {
 	db, _ := strconv.Atoi(getEnvWithDefault("REDIS_DB", "0"))
 	ttl, _ := strconv.ParseInt(getEnvWithDefault("CACHE_TTL", "3600"), 10, 64)
 	trendingTTL, _ := strconv.ParseInt(getEnvWithDefault("TRENDING_CACHE_TTL", "900"), 10, 64)
+	thumbnailSize := getInt64EnvWithDefault("THUMBNAIL_SIZE", 64)
+	if thumbnailSize > maxThumbnailSize {
+		log.Printf("⚠️  THUMBNAIL_SIZE %d is too large, using %d", thumbnailSize, maxThumbnailSize)
+		thumbnailSize = maxThumbnailSize
+	}
+	maxImageSize := getInt64EnvWithDefault("MAX_IMAGE_SIZE", 10485760)
+	sasExpiry := getInt64EnvWithDefault("STORAGE_SAS_EXPIRY", 3600)
 
 	// Get Azure connection string with logging
 	azureConnStr := os.Getenv("AZURE_CONNECTION_STRING")
@@ -51,6 +148,29 @@ func LoadConfig() *Config {
 		APITitle:         getEnvWithDefault("API_TITLE", "7TV Emote API"),
 		APIDesc:          getEnvWithDefault("API_DESCRIPTION", "API for fetching and storing 7TV emotes"),
 		APIVersion:       getEnvWithDefault("API_VERSION", "1.0.0"),
+
+		ShowDeletedEmotes:  getBoolEnvWithDefault("SHOW_DELETED_EMOTES", false),
+		ShowPrivateEmotes:  getBoolEnvWithDefault("SHOW_PRIVATE_EMOTES", false),
+		ShowUnlistedEmotes: getBoolEnvWithDefault("SHOW_UNLISTED_EMOTES", false),
+		ShowPendingEmotes:  getBoolEnvWithDefault("SHOW_PENDING_EMOTES", false),
+
+		StorageFolders: getListEnvWithDefault("STORAGE_FOLDERS", []string{"emote_api", "trending_emotes", "emote_sets"}),
+
+		MaxImageSize: maxImageSize,
+
+		GeneratePreviews: getBoolEnvWithDefault("GENERATE_PREVIEWS", true),
+		ThumbnailSize:    int(thumbnailSize),
+
+		ImageDeliveryMode: getEnvWithDefault("IMAGE_DELIVERY_MODE", "redirect"),
+
+		PublicBaseURL:  strings.TrimSuffix(os.Getenv("PUBLIC_BASE_URL"), "/"),
+		PublicBaseURLs: getMapEnv("PUBLIC_BASE_URL_OVERRIDES"),
+		CDNPurgeURL:    os.Getenv("CDN_PURGE_URL"),
+		CDNPurgeToken:  os.Getenv("CDN_PURGE_TOKEN"),
+
+		SASMode:        strings.ToLower(os.Getenv("STORAGE_SAS_MODE")),
+		SASExpiry:      time.Duration(sasExpiry) * time.Second,
+		SASPermissions: getEnvWithDefault("STORAGE_SAS_PERMISSIONS", "r"),
 	}
 
 	// Log configuration with sensitive data masked
@@ -70,5 +190,14 @@ func LogConfiguration(cfg *Config) {
 		log.Printf("  ⚠️  Azure Storage: DISABLED (connection string not set)")
 	} else {
 		log.Printf("  ✅ Azure Storage: ENABLED (Container: %s)", cfg.ContainerName)
+		if cfg.PublicBaseURL != "" || len(cfg.PublicBaseURLs) > 0 {
+			log.Printf("  🌐 Public base URL: %s (%d folder overrides)", cfg.PublicBaseURL, len(cfg.PublicBaseURLs))
+		}
+		if cfg.CDNPurgeURL != "" {
+			log.Printf("  🧹 CDN purge: ENABLED")
+		}
+		if cfg.SASMode != "" {
+			log.Printf("  🔑 Signed URLs: %s SAS (expiry: %v, permissions: %s)", cfg.SASMode, cfg.SASExpiry, cfg.SASPermissions)
+		}
 	}
 }
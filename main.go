@@ -43,13 +43,23 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Welcome to the 7TV Emote API",
 			"endpoints": gin.H{
-				"search":           "/api/search-emotes",
-				"trending_emotes":  "/api/trending/emotes",
-				"storage_trending": "/api/storage/trending-emotes",
-				"storage_emotes":   "/api/storage/emote-api",
-				"cache_status":     "/api/cache/status",
-				"clear_cache":      "/api/cache/clear",
-				"health":           "/health",
+				"search":             "/api/search-emotes",
+				"emote_image":        "/api/emotes/:id/image",
+				"emote_resize":       "/api/emotes/:id/resize",
+				"export_presets":     "/api/export/presets",
+				"export":             "/api/export/:preset",
+				"emote_pack":         "/api/pack",
+				"sprite_sheet":       "/api/sprites",
+				"trending_emotes":    "/api/trending/emotes",
+				"trending_periods":   "/api/trending/periods",
+				"storage_trending":   "/api/storage/trending-emotes",
+				"storage_emotes":     "/api/storage/emote-api",
+				"storage_search":     "/api/storage/search",
+				"storage_duplicates": "/api/storage/duplicates",
+				"storage_folders":    "/api/storage/folders",
+				"cache_status":       "/api/cache/status",
+				"clear_cache":        "/api/cache/clear",
+				"health":             "/health",
 			},
 			"documentation": "/docs",
 		})
@@ -1,15 +1,110 @@
 // models/models.go
 package models
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type EmoteResponse struct {
 	FileName  string `json:"fileName"`
 	URL       string `json:"url"`
 	EmoteID   string `json:"emoteId"`
 	EmoteName string `json:"emoteName"`
 	Owner     string `json:"owner,omitempty"`
+	OwnerID   string `json:"ownerId,omitempty"`
 	Animated  bool   `json:"animated,omitempty"`
 	Scale     int    `json:"scale,omitempty"`
 	Mime      string `json:"mime,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+
+	// PreviewURL is a static PNG of the first frame of animated emotes and
+	// ThumbnailURL a PNG scaled to fit THUMBNAIL_SIZE pixels square.
+	PreviewURL   string `json:"previewUrl,omitempty"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+
+	// PHash is the perceptual hash (dHash) of the first frame, used to find
+	// near-duplicate emotes.
+	PHash string `json:"phash,omitempty"`
+
+	// Animation is read from the mirrored GIF or WebP file; nil for static
+	// emotes and formats that aren't parsed.
+	Animation *Animation `json:"animation,omitempty"`
+
+	// ContentHash is the SHA-256 of the image, which is also its address in
+	// storage.
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// Rank is the position in the requested ordering (1 = first) and
+	// RankingScore the 7TV ranking for RankingPeriod.
+	Rank          int    `json:"rank,omitempty"`
+	RankingScore  int    `json:"rankingScore,omitempty"`
+	RankingPeriod string `json:"rankingPeriod,omitempty"`
+
+	OwnerStyle *OwnerStyle `json:"ownerStyle,omitempty"`
+	Flags      *EmoteFlags `json:"flags,omitempty"`
+}
+
+// Animation describes the timing of an animated image. Delays are per frame
+// in milliseconds as encoded in the file and DurationMs their sum;
+// PlaybackDurationMs counts delays of 10 ms or less as 100 ms, as browsers
+// play them. LoopCount is how many times the animation plays, 0 meaning
+// forever.
+type Animation struct {
+	FrameCount         int   `json:"frameCount"`
+	DurationMs         int   `json:"durationMs"`
+	PlaybackDurationMs int   `json:"playbackDurationMs"`
+	LoopCount          int   `json:"loopCount"`
+	Delays             []int `json:"delays,omitempty"`
+}
+
+// OwnerStyle describes how 7TV renders the owner's name: the color of their
+// highest role and their active paint, if any.
+type OwnerStyle struct {
+	Color string `json:"color,omitempty"`
+	Paint *Paint `json:"paint,omitempty"`
+}
+
+type Paint struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name"`
+	Layers  []PaintLayer  `json:"layers,omitempty"`
+	Shadows []PaintShadow `json:"shadows,omitempty"`
+}
+
+// PaintLayer is one layer of a paint. Type is one of single_color,
+// linear_gradient, radial_gradient or image and determines which fields are set.
+type PaintLayer struct {
+	Type      string      `json:"type"`
+	Opacity   float64     `json:"opacity"`
+	Color     string      `json:"color,omitempty"`
+	Angle     float64     `json:"angle,omitempty"`
+	Repeating bool        `json:"repeating,omitempty"`
+	Shape     string      `json:"shape,omitempty"`
+	Stops     []PaintStop `json:"stops,omitempty"`
+	ImageURL  string      `json:"imageUrl,omitempty"`
+}
+
+type PaintStop struct {
+	At    float64 `json:"at"`
+	Color string  `json:"color"`
+}
+
+type PaintShadow struct {
+	Color   string  `json:"color"`
+	OffsetX float64 `json:"offsetX"`
+	OffsetY float64 `json:"offsetY"`
+	Blur    float64 `json:"blur"`
+}
+
+type EmoteFlags struct {
+	Deleted       bool `json:"deleted"`
+	Private       bool `json:"private"`
+	PublicListed  bool `json:"publicListed"`
+	ZeroWidth     bool `json:"zeroWidth"`
+	ImagesPending bool `json:"imagesPending"`
 }
 
 type SearchResponse struct {
@@ -23,13 +118,162 @@ type SearchResponse struct {
 	TotalPages     int             `json:"totalPages,omitempty"`
 	ResultsPerPage int             `json:"resultsPerPage,omitempty"`
 	HasNextPage    bool            `json:"hasNextPage,omitempty"`
+	NextCursor     string          `json:"nextCursor,omitempty"`
+	Filtered       *FilterStats    `json:"filtered,omitempty"`
+	Failures       []EmoteFailure  `json:"failures,omitempty"`
+}
+
+// FilterStats counts the emotes removed by the visibility policy.
+type FilterStats struct {
+	Total    int `json:"total"`
+	Deleted  int `json:"deleted"`
+	Private  int `json:"private"`
+	Unlisted int `json:"unlisted"`
+	Pending  int `json:"pending"`
+}
+
+// EmoteFailure records an emote that could not be mirrored and why.
+type EmoteFailure struct {
+	EmoteID   string `json:"emoteId"`
+	EmoteName string `json:"emoteName"`
+	Reason    string `json:"reason"`
+}
+
+// ExportFile is one file produced by an export preset. Adjustments lists the
+// reductions made to meet the platform limits and Issues the constraints the
+// file still doesn't meet.
+type ExportFile struct {
+	EmoteID     string   `json:"emoteId"`
+	EmoteName   string   `json:"emoteName"`
+	FileName    string   `json:"fileName,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Width       int      `json:"width"`
+	Height      int      `json:"height"`
+	Format      string   `json:"format"`
+	Bytes       int      `json:"bytes,omitempty"`
+	Frames      int      `json:"frames,omitempty"`
+	Compliant   bool     `json:"compliant"`
+	Adjustments []string `json:"adjustments,omitempty"`
+	Issues      []string `json:"issues,omitempty"`
+}
+
+// ExportResponse lists the files exported for an emote or emote set.
+type ExportResponse struct {
+	Success        bool         `json:"success"`
+	Preset         string       `json:"preset"`
+	Platform       string       `json:"platform"`
+	EmoteSetID     string       `json:"emoteSetId,omitempty"`
+	EmoteSetName   string       `json:"emoteSetName,omitempty"`
+	TotalFound     int          `json:"totalFound"`
+	Compliant      bool         `json:"compliant"`
+	Files          []ExportFile `json:"files"`
+	Message        string       `json:"message,omitempty"`
+	ProcessingTime float64      `json:"processingTime,omitempty"`
+	Page           int          `json:"page,omitempty"`
+	TotalPages     int          `json:"totalPages,omitempty"`
+	ResultsPerPage int          `json:"resultsPerPage,omitempty"`
+	HasNextPage    bool         `json:"hasNextPage,omitempty"`
+	Filtered       *FilterStats `json:"filtered,omitempty"`
+}
+
+// PackManifest is the manifest.json of an emote pack. In a pack, the fileName
+// of each emote is its path inside the archive.
+type PackManifest struct {
+	Source      string          `json:"source"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+	TotalFound  int             `json:"totalFound"`
+	Emotes      []EmoteResponse `json:"emotes"`
+	Failures    []EmoteFailure  `json:"failures,omitempty"`
+}
+
+// SpriteAtlas locates each emote of a sprite sheet by alias. Image is the file
+// name of the sheet, next to the atlas. Failures lists the emotes whose image
+// couldn't be decoded when the sheet was generated.
+type SpriteAtlas struct {
+	Image    string                 `json:"image"`
+	Width    int                    `json:"width"`
+	Height   int                    `json:"height"`
+	Size     int                    `json:"size"`
+	Frames   map[string]SpriteFrame `json:"frames"`
+	Failures []EmoteFailure         `json:"failures,omitempty"`
+}
+
+type SpriteFrame struct {
+	EmoteID string `json:"emoteId"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	W       int    `json:"w"`
+	H       int    `json:"h"`
+}
+
+// SpriteSheetResponse describes a stored sprite sheet.
+type SpriteSheetResponse struct {
+	Success        bool           `json:"success"`
+	EmoteSetID     string         `json:"emoteSetId,omitempty"`
+	EmoteSetName   string         `json:"emoteSetName,omitempty"`
+	Fingerprint    string         `json:"fingerprint"`
+	ImageURL       string         `json:"imageUrl"`
+	AtlasURL       string         `json:"atlasUrl"`
+	Generated      bool           `json:"generated"`
+	Atlas          SpriteAtlas    `json:"atlas"`
+	Failures       []EmoteFailure `json:"failures,omitempty"`
+	ProcessingTime float64        `json:"processingTime,omitempty"`
+}
+
+// DuplicateEntry is a stored emote of a near-duplicate cluster, with the
+// Hamming distance between its perceptual hash and the cluster's first emote.
+type DuplicateEntry struct {
+	EmoteResponse
+	Distance int `json:"distance"`
+}
+
+// DuplicateCluster groups stored emotes whose perceptual hashes are within
+// the requested threshold of each other.
+type DuplicateCluster struct {
+	Emotes []DuplicateEntry `json:"emotes"`
+}
+
+type DuplicatesResponse struct {
+	Success        bool               `json:"success"`
+	Folder         string             `json:"folder"`
+	EmoteID        string             `json:"emoteId,omitempty"`
+	Threshold      int                `json:"threshold"`
+	TotalClusters  int                `json:"totalClusters"`
+	Clusters       []DuplicateCluster `json:"clusters"`
+	Unhashed       int                `json:"unhashed,omitempty"`
+	Message        string             `json:"message,omitempty"`
+	ProcessingTime float64            `json:"processingTime,omitempty"`
 }
 
 type SearchRequest struct {
-    Query        string `json:"query"`
-    Limit        int    `json:"limit,omitempty"`
-    PerPage      int    `json:"perPage,omitempty"`
-    AnimatedOnly bool   `json:"animated_only,omitempty"`
+	Query        string `json:"query"`
+	Limit        int    `json:"limit,omitempty"`
+	PerPage      int    `json:"perPage,omitempty"`
+	AnimatedOnly bool   `json:"animated_only,omitempty"`
+
+	// Image selection policy
+	Formats  []string `json:"formats,omitempty"`
+	MaxScale int      `json:"max_scale,omitempty"`
+	MaxWidth int      `json:"max_width,omitempty"`
+	Variant  string   `json:"variant,omitempty"`
+
+	// Search filters
+	Tags          []string `json:"tags,omitempty"`
+	TagMatch      string   `json:"tag_match,omitempty"`
+	ExactMatch    bool     `json:"exact_match,omitempty"`
+	ZeroWidth     *bool    `json:"zero_width,omitempty"`
+	Owner         string   `json:"owner,omitempty"`
+	CaseSensitive bool     `json:"case_sensitive,omitempty"`
+
+	// Sorting
+	SortBy    string `json:"sort_by,omitempty"`
+	SortOrder string `json:"sort_order,omitempty"`
+
+	// Visibility overrides, nil keeps the configured default
+	ShowDeleted  *bool `json:"show_deleted,omitempty"`
+	ShowPrivate  *bool `json:"show_private,omitempty"`
+	ShowUnlisted *bool `json:"show_unlisted,omitempty"`
+	ShowPending  *bool `json:"show_pending,omitempty"`
 }
 
 type TrendingPeriod string
@@ -40,3 +284,21 @@ const (
 	Monthly TrendingPeriod = "trending_monthly"
 	AllTime TrendingPeriod = "popularity"
 )
+
+// SupportedPeriods lists the trending periods accepted by the API, in the
+// order they are presented to clients.
+var SupportedPeriods = []TrendingPeriod{Daily, Weekly, Monthly, AllTime}
+
+// ParseTrendingPeriod validates a period received from a client.
+func ParseTrendingPeriod(s string) (TrendingPeriod, error) {
+	for _, p := range SupportedPeriods {
+		if TrendingPeriod(s) == p {
+			return p, nil
+		}
+	}
+	names := make([]string, len(SupportedPeriods))
+	for i, p := range SupportedPeriods {
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("invalid period %q. Use one of: %s", s, strings.Join(names, ", "))
+}
@@ -47,6 +47,7 @@ func cacheStatus(c *gin.Context) {
 	dbsize, _ := cache.RedisClient.DBSize(context.Background()).Result()
 	emoteSearchKeys, _ := cache.RedisClient.Keys(context.Background(), "emote_search:*").Result()
 	trendingKeys, _ := cache.RedisClient.Keys(context.Background(), "trending:*").Result()
+	storageKeys, _ := cache.RedisClient.Keys(context.Background(), "storage_*").Result()
 
 	usedMemory := "unknown"
 	hits := 0
@@ -77,6 +78,7 @@ func cacheStatus(c *gin.Context) {
 		"totalKeys":       dbsize,
 		"emoteSearchKeys": len(emoteSearchKeys),
 		"trendingKeys":    len(trendingKeys),
+		"storageKeys":     len(storageKeys),
 		"usedMemory":      usedMemory,
 		"hitRatio":        hitRatio,
 	})
@@ -91,13 +93,15 @@ func clearCache(c *gin.Context) {
 	var patterns []string
 	switch cacheType {
 	case "all":
-		patterns = []string{"emote_search:*", "trending:*"}
+		patterns = []string{"emote_search:*", "trending:*", "storage_*", "emote:*", "emote_set:*"}
 	case "search":
 		patterns = []string{"emote_search:*"}
 	case "trending":
 		patterns = []string{"trending:*"}
+	case "storage":
+		patterns = []string{"storage_*"}
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid cache_type. Options are: all, search, trending"})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid cache_type. Options are: all, search, trending, storage"})
 		return
 	}
 
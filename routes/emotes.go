@@ -28,37 +28,59 @@ func getEmoteLimiter() gin.HandlerFunc {
 }
 
 func searchEmotes(c *gin.Context) {
-    start := time.Now()
-    var req models.SearchRequest
-    if err := c.BindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-    // Support both `limit` (internal) and `perPage` (7TV naming)
-    if req.Limit == 0 && req.PerPage > 0 {
-        req.Limit = req.PerPage
-    }
-    if req.Query == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"detail": "Query parameter is required"})
-        return
-    }
-    if req.Limit == 0 || req.Limit > 200 {
-        req.Limit = 100
-    }
+	start := time.Now()
+	var req models.SearchRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Support both `limit` (internal) and `perPage` (7TV naming)
+	if req.Limit == 0 && req.PerPage > 0 {
+		req.Limit = req.PerPage
+	}
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"detail": "Query parameter is required"})
+		return
+	}
+	if req.Limit == 0 || req.Limit > 200 {
+		req.Limit = 100
+	}
+	policy, err := seventv.ParseImageSelectionPolicy(req.Formats, req.MaxScale, req.MaxWidth, req.Variant)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"detail": err.Error()})
+		return
+	}
 
-	cacheKey := cache.GetCacheKey(req.Query, req.Limit, req.AnimatedOnly)
+	filters, err := seventv.ParseSearchFilters(req.Tags, req.TagMatch, req.ExactMatch, req.ZeroWidth, req.Owner, req.CaseSensitive)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"detail": err.Error()})
+		return
+	}
+
+	searchSort, err := seventv.ParseSearchSort(req.SortBy, req.SortOrder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"detail": err.Error()})
+		return
+	}
+
+	visibility := seventv.NewVisibilityPolicy(config.LoadConfig()).
+		WithOverrides(req.ShowDeleted, req.ShowPrivate, req.ShowUnlisted, req.ShowPending)
+
+	cacheKey := cache.GetCacheKey(req.Query, req.Limit, req.AnimatedOnly, policy.Key(), filters.Key(), searchSort.Key(), visibility.Key())
 	cached, err := cache.GetFromCache(cacheKey)
 	if err == nil && cached != nil {
 		var resp models.SearchResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			resp.ProcessingTime = time.Since(start).Seconds()
 			resp.Cached = true
+			presentEmotes(resp.Emotes)
 			c.JSON(http.StatusOK, resp)
 			return
 		}
 	}
 
-	emotes := seventv.Fetch7TVEmotesAPI(req.Query, req.Limit, req.AnimatedOnly)
+	emotes := seventv.Fetch7TVEmotesAPI(req.Query, req.Limit, req.AnimatedOnly, filters, searchSort)
+	emotes, stats := seventv.FilterVisible(emotes, visibility)
 	if len(emotes) == 0 {
 		resp := models.SearchResponse{
 			Success:        true,
@@ -66,20 +88,36 @@ func searchEmotes(c *gin.Context) {
 			Emotes:         []models.EmoteResponse{},
 			Message:        "No emotes found for the given query",
 			ProcessingTime: time.Since(start).Seconds(),
+			Filtered:       filteredStats(stats),
 		}
 		cache.SaveToCache(cacheKey, resp, config.LoadConfig().CacheTTL)
 		c.JSON(http.StatusOK, resp)
 		return
 	}
 
-	processed := seventv.ProcessEmotesBatch(emotes, "emote_api")
+	processed, failures := seventv.ProcessEmotesBatch(emotes, emoteFolder, policy)
 
 	resp := models.SearchResponse{
 		Success:        true,
 		TotalFound:     len(emotes),
 		Emotes:         processed,
 		ProcessingTime: time.Since(start).Seconds(),
+		Filtered:       filteredStats(stats),
+		Failures:       failures,
 	}
-	cache.SaveToCache(cacheKey, resp, config.LoadConfig().CacheTTL)
+	// Failed emotes are retried on the next request instead of being cached
+	if len(failures) == 0 {
+		cache.SaveToCache(cacheKey, resp, config.LoadConfig().CacheTTL)
+	}
+	presentEmotes(resp.Emotes)
 	c.JSON(http.StatusOK, resp)
 }
+
+// filteredStats returns the visibility counts to report, or nil when nothing
+// was filtered out.
+func filteredStats(stats models.FilterStats) *models.FilterStats {
+	if stats.Total == 0 {
+		return nil
+	}
+	return &stats
+}
@@ -0,0 +1,133 @@
+// routes/export.go
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gokeki/config"
+	"gokeki/models"
+	"gokeki/services/cache"
+	"gokeki/services/seventv"
+	"gokeki/services/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ulule/limiter/v3"
+	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
+	sredis "github.com/ulule/limiter/v3/drivers/store/redis"
+)
+
+// maxExportEmotes caps the emotes exported per request. Every emote is
+// rendered at each preset size, so set exports are paged.
+const maxExportEmotes = 20
+
+func getExportLimiter() gin.HandlerFunc {
+	store, err := sredis.NewStore(cache.RedisClient)
+	if err != nil {
+		panic(err)
+	}
+	rate := limiter.Rate{Period: 15 * time.Minute, Limit: 30}
+	l := limiter.New(store, rate)
+	return mgin.NewMiddleware(l)
+}
+
+// exportPresets lists the supported export presets.
+func exportPresets(c *gin.Context) {
+	presets := []seventv.ExportPreset{}
+	for _, name := range seventv.ExportPresetNames() {
+		preset, _ := seventv.GetExportPreset(name)
+		presets = append(presets, preset)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "presets": presets})
+}
+
+// exportEmotes produces the files of a platform preset for one emote
+// (emote_id) or a page of an emote set (set_id, page and limit).
+func exportEmotes(c *gin.Context) {
+	start := time.Now()
+	page, limit := parsePageParams(c)
+	if limit > maxExportEmotes {
+		limit = maxExportEmotes
+	}
+	preset, ok := seventv.GetExportPreset(strings.ToLower(c.Param("preset")))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":          false,
+			"message":          fmt.Sprintf("Unknown preset: %s", c.Param("preset")),
+			"supportedPresets": seventv.ExportPresetNames(),
+		})
+		return
+	}
+
+	emotes, set, status, err := exportSource(c)
+	if err != nil {
+		c.JSON(status, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	folder := emoteFolder
+	if set != nil {
+		folder = emoteSetFolder
+	}
+
+	emotes, stats := seventv.FilterVisible(emotes, seventv.NewVisibilityPolicy(config.LoadConfig()).ForLookup())
+	startIdx, endIdx, totalPages := pageBounds(len(emotes), page, limit)
+	files := seventv.ExportEmotes(emotes[startIdx:endIdx], folder, preset)
+
+	resp := models.ExportResponse{
+		Success:        true,
+		Preset:         preset.Name,
+		Platform:       preset.Platform,
+		TotalFound:     len(emotes),
+		Compliant:      len(files) > 0,
+		Files:          files,
+		ProcessingTime: time.Since(start).Seconds(),
+		Page:           page,
+		TotalPages:     totalPages,
+		ResultsPerPage: limit,
+		HasNextPage:    page < totalPages,
+		Filtered:       filteredStats(stats),
+	}
+	if set != nil {
+		resp.EmoteSetID, resp.EmoteSetName = set.ID, set.Name
+	}
+	for i := range resp.Files {
+		resp.Files[i].URL = storage.PresentURL(resp.Files[i].URL)
+		if !resp.Files[i].Compliant {
+			resp.Compliant = false
+		}
+	}
+	if len(files) == 0 {
+		resp.Files = []models.ExportFile{}
+		resp.Message = "No emotes to export"
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// exportSource resolves the emote_id or set_id query parameter to the emotes
+// to export, with the HTTP status to use on failure. The set is nil for a
+// single emote.
+func exportSource(c *gin.Context) ([]seventv.Emote, *seventv.EmoteSet, int, error) {
+	emoteID, setID := c.Query("emote_id"), c.Query("set_id")
+	if (emoteID == "") == (setID == "") {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("exactly one of emote_id or set_id is required")
+	}
+	if !storage.AzureStorageAvailable() {
+		return nil, nil, http.StatusServiceUnavailable, fmt.Errorf("Azure Storage is not properly configured or unavailable")
+	}
+
+	if setID != "" {
+		set := seventv.GetEmoteSet(setID)
+		if set == nil {
+			return nil, nil, http.StatusNotFound, fmt.Errorf("emote set %s not found", setID)
+		}
+		return set.EmoteList(), set, http.StatusOK, nil
+	}
+
+	e := seventv.GetEmote(emoteID)
+	if e == nil {
+		return nil, nil, http.StatusNotFound, fmt.Errorf("emote %s not found", emoteID)
+	}
+	return []seventv.Emote{*e}, nil, http.StatusOK, nil
+}
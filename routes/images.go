@@ -0,0 +1,188 @@
+// routes/images.go
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"gokeki/config"
+	"gokeki/models"
+	"gokeki/services/cache"
+	"gokeki/services/seventv"
+	"gokeki/services/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ulule/limiter/v3"
+	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
+	sredis "github.com/ulule/limiter/v3/drivers/store/redis"
+)
+
+// imageMaxAge is how long responses of the image endpoints may be cached. The
+// URLs name an emote, not an image, and may point at new content later; the
+// ETag is the content hash, so revalidating afterwards is cheap.
+const imageMaxAge = 3600
+
+func getImageLimiter() gin.HandlerFunc {
+	store, err := sredis.NewStore(cache.RedisClient)
+	if err != nil {
+		panic(err)
+	}
+	rate := limiter.Rate{Period: time.Minute, Limit: 600}
+	l := limiter.New(store, rate)
+	return mgin.NewMiddleware(l)
+}
+
+// emoteImage serves the mirrored image of an emote, mirroring it on demand.
+// Query parameters: scale (1-4, highest scale to serve), format (webp, gif,
+// avif, png), variant (auto, animated, static) and mode (redirect or proxy,
+// IMAGE_DELIVERY_MODE by default).
+func emoteImage(c *gin.Context) {
+	cfg := config.LoadConfig()
+	mode := c.DefaultQuery("mode", cfg.ImageDeliveryMode)
+	if mode != "redirect" && mode != "proxy" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid mode. Use 'redirect' or 'proxy'"})
+		return
+	}
+
+	mirrored, status, err := resolveEmoteImage(c)
+	if err != nil {
+		c.JSON(status, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	contentBlob := storage.ContentBlobName(mirrored.ContentHash, path.Ext(mirrored.FileName))
+
+	if mode == "redirect" {
+		// Never cached longer than a signed URL stays valid
+		public := storage.PublicURL(emoteFolder, contentBlob)
+		target := storage.PresentURL(public)
+		maxAge := imageMaxAge
+		if target != public && int(cfg.SASExpiry.Seconds()/2) < maxAge {
+			maxAge = int(cfg.SASExpiry.Seconds() / 2)
+		}
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+
+	serveBlob(c, contentBlob, mirrored.ContentHash, fmt.Sprintf("public, max-age=%d", imageMaxAge))
+}
+
+// resolveEmoteImage looks up the emote named by the :id parameter and returns
+// its mirrored image for the requested scale, format and variant, with the
+// HTTP status to use on failure.
+func resolveEmoteImage(c *gin.Context) (*models.EmoteResponse, int, error) {
+	scale, err := optionalIntQuery(c, "scale")
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	var formats []string
+	if format := c.Query("format"); format != "" {
+		formats = []string{format}
+	}
+	policy, err := seventv.ParseImageSelectionPolicy(formats, scale, 0, c.Query("variant"))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	e, status, err := lookupEmote(c)
+	if err != nil {
+		return nil, status, err
+	}
+
+	mirrored, err := seventv.MirrorEmote(*e, emoteFolder, policy)
+	if err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("could not mirror the image of emote %s: %v", e.ID, err)
+	}
+	if mirrored == nil || mirrored.ContentHash == "" {
+		return nil, http.StatusBadGateway, fmt.Errorf("could not mirror the image of emote %s", e.ID)
+	}
+	return mirrored, http.StatusOK, nil
+}
+
+// lookupEmote returns the visible emote named by the :id parameter, with the
+// HTTP status to use on failure. Storage must be available to serve it.
+func lookupEmote(c *gin.Context) (*seventv.Emote, int, error) {
+	if !storage.AzureStorageAvailable() {
+		return nil, http.StatusServiceUnavailable, fmt.Errorf("Azure Storage is not properly configured or unavailable")
+	}
+
+	id := c.Param("id")
+	e := seventv.GetEmote(id)
+	if e == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("emote %s not found", id)
+	}
+	if visible, _ := seventv.FilterVisible([]seventv.Emote{*e}, seventv.NewVisibilityPolicy(config.LoadConfig()).ForLookup()); len(visible) == 0 {
+		return nil, http.StatusNotFound, fmt.Errorf("emote %s not found", id)
+	}
+	return e, http.StatusOK, nil
+}
+
+// resizeEmoteImage serves an emote resized to an exact pixel size. Query
+// parameters: width and height (1-1024, at least one; a missing one keeps the
+// aspect ratio), fit (contain, cover, fill) and format (png for the first
+// frame, gif to keep GIF animations). Renditions are stored under derived/
+// and reused.
+func resizeEmoteImage(c *gin.Context) {
+	opts, err := seventv.ParseResizeOptions(c.Query("width"), c.Query("height"), c.Query("fit"), c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	e, status, err := lookupEmote(c)
+	if err != nil {
+		c.JSON(status, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	blobName, data, err := seventv.ResizeEmote(*e, emoteFolder, opts)
+	if errors.Is(err, seventv.ErrNotResizable) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"success": false, "message": fmt.Sprintf("emote %s has no image that can be resized", e.ID)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": fmt.Sprintf("could not resize emote %s: %v", e.ID, err)})
+		return
+	}
+
+	etag := path.Base(blobName)
+	cacheControl := fmt.Sprintf("public, max-age=%d", imageMaxAge)
+	if data != nil {
+		serveContent(c, blobName, opts.ContentType(), etag, cacheControl, time.Now(), bytes.NewReader(data))
+		return
+	}
+	serveBlob(c, blobName, etag, cacheControl)
+}
+
+// serveBlob streams a blob with caching headers. Range and conditional
+// requests are handled by http.ServeContent, which only downloads the bytes
+// it sends.
+func serveBlob(c *gin.Context, blobName, etag, cacheControl string) {
+	r, found, err := storage.OpenBlob(blobName)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": fmt.Sprintf("error accessing Azure Storage: %v", err)})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "image not found in storage"})
+		return
+	}
+	defer r.Close()
+
+	serveContent(c, blobName, r.ContentType, etag, cacheControl, r.LastModified, r)
+}
+
+// serveContent writes an image with caching headers.
+func serveContent(c *gin.Context, blobName, contentType, etag, cacheControl string, modTime time.Time, content io.ReadSeeker) {
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("Cache-Control", cacheControl)
+	c.Header("ETag", `"`+etag+`"`)
+	http.ServeContent(c.Writer, c.Request, path.Base(blobName), modTime, content)
+}
@@ -0,0 +1,168 @@
+// routes/pack.go
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gokeki/config"
+	"gokeki/models"
+	"gokeki/services/cache"
+	"gokeki/services/seventv"
+	"gokeki/services/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ulule/limiter/v3"
+	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
+	sredis "github.com/ulule/limiter/v3/drivers/store/redis"
+)
+
+func getPackLimiter() gin.HandlerFunc {
+	store, err := sredis.NewStore(cache.RedisClient)
+	if err != nil {
+		panic(err)
+	}
+	rate := limiter.Rate{Period: 15 * time.Minute, Limit: 10}
+	l := limiter.New(store, rate)
+	return mgin.NewMiddleware(l)
+}
+
+// emotePack streams a ZIP of mirrored emote images with a manifest.json. The
+// emotes come from exactly one of: ids (comma separated emote IDs), q (search
+// query), period (trending period) or set_id (emote set). emote_type (all,
+// animated or static, default all) narrows searches and trending. limit caps
+// the number of emotes (default 100, max 1000) and formats, max_scale,
+// max_width and variant pick the image of each emote.
+func emotePack(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	maxScale, err := optionalIntQuery(c, "max_scale")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	maxWidth, err := optionalIntQuery(c, "max_width")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	policy, err := seventv.ParseImageSelectionPolicy(seventv.SplitFormats(c.Query("formats")), maxScale, maxWidth, c.Query("variant"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	emotes, folder, source, status, err := packSource(c, limit)
+	if err != nil {
+		c.JSON(status, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	// Unlisted emotes are kept when they were asked for by ID or set
+	visibility := seventv.NewVisibilityPolicy(config.LoadConfig())
+	if c.Query("ids") != "" || c.Query("set_id") != "" {
+		visibility = visibility.ForLookup()
+	}
+	emotes, _ = seventv.FilterVisible(emotes, visibility)
+	if len(emotes) > limit {
+		emotes = emotes[:limit]
+	}
+	if len(emotes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "No emotes found for the given source"})
+		return
+	}
+
+	mirrored, failures := seventv.MirrorEmotesBatch(emotes, folder, policy)
+	presentEmotes(mirrored)
+	manifest := models.PackManifest{
+		Source:      source,
+		GeneratedAt: time.Now().UTC(),
+		TotalFound:  len(emotes),
+		Emotes:      mirrored,
+		Failures:    failures,
+	}
+
+	fileName := "emotes_" + seventv.SafeFileName(source) + ".zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Status(http.StatusOK)
+	if err := seventv.WritePack(c.Writer, manifest); err != nil {
+		// Headers are already sent; the client gets a truncated archive
+		log.Printf("Error writing emote pack %s: %v", source, err)
+	}
+}
+
+// packSource resolves the pack source parameters to the emotes to pack, the
+// folder they are mirrored into and a description of the source, with the
+// HTTP status to use on failure.
+func packSource(c *gin.Context, limit int) ([]seventv.Emote, string, string, int, error) {
+	ids, query, period, setID := c.Query("ids"), c.Query("q"), c.Query("period"), c.Query("set_id")
+	animationFilter, err := seventv.ParseAnimationFilter(c.DefaultQuery("emote_type", "all"))
+	if err != nil {
+		return nil, "", "", http.StatusBadRequest, err
+	}
+	given := 0
+	for _, v := range []string{ids, query, period, setID} {
+		if v != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		return nil, "", "", http.StatusBadRequest, fmt.Errorf("exactly one of ids, q, period or set_id is required")
+	}
+	if !storage.AzureStorageAvailable() {
+		return nil, "", "", http.StatusServiceUnavailable, fmt.Errorf("Azure Storage is not properly configured or unavailable")
+	}
+
+	switch {
+	case ids != "":
+		emotes, missing := seventv.GetEmotes(parseIDList(ids, limit))
+		if len(missing) > 0 {
+			return nil, "", "", http.StatusNotFound, fmt.Errorf("emotes not found: %s", strings.Join(missing, ", "))
+		}
+		return emotes, emoteFolder, "ids", http.StatusOK, nil
+	case query != "":
+		filters, _ := seventv.ParseSearchFilters(nil, "", false, nil, "", false)
+		emotes := seventv.Fetch7TVEmotesAdvanced(query, limit, animationFilter, filters, seventv.DefaultSearchSort())
+		return emotes, emoteFolder, "search_" + query, http.StatusOK, nil
+	case period != "":
+		p, err := models.ParseTrendingPeriod(period)
+		if err != nil {
+			return nil, "", "", http.StatusBadRequest, err
+		}
+		emotes := seventv.Fetch7TVTrendingEmotesAdvanced(p, limit, animationFilter)
+		return emotes, trendingFolder, "trending_" + string(p), http.StatusOK, nil
+	default:
+		set := seventv.GetEmoteSet(setID)
+		if set == nil {
+			return nil, "", "", http.StatusNotFound, fmt.Errorf("emote set %s not found", setID)
+		}
+		return set.EmoteList(), emoteSetFolder, "set_" + set.Name, http.StatusOK, nil
+	}
+}
+
+// parseIDList splits a comma separated list of IDs, dropping blanks and
+// duplicates, and keeps at most limit of them.
+func parseIDList(s string, limit int) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
@@ -0,0 +1,32 @@
+// routes/pack_test.go
+package routes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIDList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		limit int
+		want  []string
+	}{
+		{name: "empty", input: "", limit: 10},
+		{name: "single", input: "01F6MQ33FG000FFJ97ZB8MWV52", limit: 10, want: []string{"01F6MQ33FG000FFJ97ZB8MWV52"}},
+		{name: "spaces and empty items", input: " a , ,b,, c ", limit: 10, want: []string{"a", "b", "c"}},
+		{name: "duplicates keep first position", input: "b,a,b,c,a", limit: 10, want: []string{"b", "a", "c"}},
+		{name: "limit", input: "a,b,c,d", limit: 2, want: []string{"a", "b"}},
+		{name: "limit counts unique ids", input: "a,a,a,b,c", limit: 2, want: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseIDList(tt.input, tt.limit)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("parseIDList(%q, %d) = %v, want %v", tt.input, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// routes/pagination.go
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gokeki/models"
+	"gokeki/services/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parsePageParams reads the page and limit query parameters shared by the
+// paginated endpoints (page >= 1, limit 1-100, default 20).
+func parsePageParams(c *gin.Context) (page int, limit int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return page, limit
+}
+
+// optionalIntQuery reads an optional integer query parameter. A missing
+// parameter is 0; anything that is not a whole number is an error.
+func optionalIntQuery(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q. Use a whole number", name, value)
+	}
+	return n, nil
+}
+
+// pageBounds returns the slice bounds of page within total items and the
+// number of pages. Pages past the end are empty.
+func pageBounds(total, page, limit int) (startIdx, endIdx, totalPages int) {
+	totalPages = (total + limit - 1) / limit
+	startIdx = (page - 1) * limit
+	if startIdx > total {
+		startIdx = total
+	}
+	endIdx = startIdx + limit
+	if endIdx > total {
+		endIdx = total
+	}
+	return startIdx, endIdx, totalPages
+}
+
+// respondStorageUnavailable answers a storage request when Azure Storage is
+// not configured.
+func respondStorageUnavailable(c *gin.Context, start time.Time, page, limit int) {
+	c.JSON(http.StatusOK, models.SearchResponse{
+		Success:        false,
+		TotalFound:     0,
+		Emotes:         []models.EmoteResponse{},
+		Message:        "Azure Storage is not properly configured or unavailable",
+		ProcessingTime: time.Since(start).Seconds(),
+		Page:           page,
+		TotalPages:     0,
+		ResultsPerPage: limit,
+		HasNextPage:    false,
+	})
+}
+
+// respondStorageError answers a storage request that failed talking to Azure.
+func respondStorageError(c *gin.Context, start time.Time, err error) {
+	c.JSON(http.StatusInternalServerError, models.SearchResponse{
+		Success:        false,
+		Message:        fmt.Sprintf("Error accessing Azure Storage: %v", err),
+		ProcessingTime: time.Since(start).Seconds(),
+	})
+}
+
+// presentEmotes prepares emote URLs for the client, signing URLs of the
+// storage container when STORAGE_SAS_MODE is set. Responses are cached with
+// unsigned URLs, so this runs after caching and on every cache hit.
+func presentEmotes(emotes []models.EmoteResponse) {
+	for i := range emotes {
+		presentEmote(&emotes[i])
+	}
+}
+
+// presentEmote prepares the URLs of a single emote, see presentEmotes.
+func presentEmote(e *models.EmoteResponse) {
+	e.URL = storage.PresentURL(e.URL)
+	e.PreviewURL = storage.PresentURL(e.PreviewURL)
+	e.ThumbnailURL = storage.PresentURL(e.ThumbnailURL)
+}
@@ -0,0 +1,44 @@
+// routes/pagination_test.go
+package routes
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOptionalIntQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{name: "missing", query: "", want: 0},
+		{name: "number", query: "?max_scale=3", want: 3},
+		{name: "negative is left to the caller", query: "?max_scale=-1", want: -1},
+		{name: "not a number", query: "?max_scale=abc", wantErr: true},
+		{name: "decimal", query: "?max_scale=2.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/emotes/pack"+tt.query, nil)
+			got, err := optionalIntQuery(c, "max_scale")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("optionalIntQuery = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,16 +5,38 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Storage folders the endpoints mirror emotes into: search results and
+// emotes requested by ID, trending emotes, and emote sets.
+const (
+	emoteFolder    = "emote_api"
+	trendingFolder = "trending_emotes"
+	emoteSetFolder = "emote_sets"
+)
+
 func SetupRoutes(r *gin.Engine) {
 	api := r.Group("/api")
 	api.POST("/search-emotes", getEmoteLimiter(), searchEmotes)
+	api.GET("/emotes/:id/image", getImageLimiter(), emoteImage)
+	api.GET("/emotes/:id/resize", getImageLimiter(), resizeEmoteImage)
 
 	trending := r.Group("/api/trending")
 	trending.GET("/emotes", getTrendingLimiter(), trendingEmotes)
+	trending.GET("/periods", getTrendingLimiter(), trendingPeriods)
 
 	storageGroup := r.Group("/api/storage")
 	storageGroup.GET("/trending-emotes", getStorageLimiter(), getTrendingEmotesFromStorage)
 	storageGroup.GET("/emote-api", getStorageLimiter(), getEmotesFromStorage)
+	storageGroup.GET("/search", getStorageLimiter(), searchStoredEmotes)
+	storageGroup.GET("/duplicates", getStorageLimiter(), findStoredDuplicates)
+	storageGroup.GET("/folders", getStorageLimiter(), listStorageFolders)
+	storageGroup.GET("/folders/:folder", getStorageLimiter(), getFolderFromStorage)
+
+	api.GET("/pack", getPackLimiter(), emotePack)
+	api.GET("/sprites", getSpriteLimiter(), spriteSheet)
+
+	exportGroup := r.Group("/api/export")
+	exportGroup.GET("/presets", getExportLimiter(), exportPresets)
+	exportGroup.GET("/:preset", getExportLimiter(), exportEmotes)
 
 	cacheGroup := r.Group("/api/cache")
 	cacheGroup.GET("/status", getCacheStatusLimiter(), cacheStatus)
@@ -0,0 +1,104 @@
+// routes/sprites.go
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gokeki/config"
+	"gokeki/models"
+	"gokeki/services/cache"
+	"gokeki/services/seventv"
+	"gokeki/services/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ulule/limiter/v3"
+	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
+	sredis "github.com/ulule/limiter/v3/drivers/store/redis"
+)
+
+// maxSpriteEmotes caps the emotes of an ID list sprite sheet.
+const maxSpriteEmotes = 500
+
+func getSpriteLimiter() gin.HandlerFunc {
+	store, err := sredis.NewStore(cache.RedisClient)
+	if err != nil {
+		panic(err)
+	}
+	rate := limiter.Rate{Period: 15 * time.Minute, Limit: 100}
+	l := limiter.New(store, rate)
+	return mgin.NewMiddleware(l)
+}
+
+// spriteSheet returns the stored sprite sheet and atlas of an emote set
+// (set_id) or a comma separated list of emote IDs (ids), generating it when
+// missing or when the emotes changed. size is the sprite height in pixels
+// (16-128, default 32).
+func spriteSheet(c *gin.Context) {
+	start := time.Now()
+	size := 32
+	if s := c.Query("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 16 || n > 128 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid size. Use 16-128"})
+			return
+		}
+		size = n
+	}
+
+	ids, setID := c.Query("ids"), c.Query("set_id")
+	if (ids == "") == (setID == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Exactly one of ids or set_id is required"})
+		return
+	}
+	if !storage.AzureStorageAvailable() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "Azure Storage is not properly configured or unavailable"})
+		return
+	}
+
+	resp := models.SpriteSheetResponse{Success: true}
+	var emotes []seventv.Emote
+	folder, source := emoteFolder, "set:"+setID
+	if setID != "" {
+		set := seventv.GetEmoteSet(setID)
+		if set == nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": fmt.Sprintf("Emote set %s not found", setID)})
+			return
+		}
+		emotes, folder = set.EmoteList(), emoteSetFolder
+		resp.EmoteSetID, resp.EmoteSetName = set.ID, set.Name
+	} else {
+		idList := parseIDList(ids, maxSpriteEmotes)
+		source = "ids:" + strings.Join(idList, ",")
+		var missing []string
+		emotes, missing = seventv.GetEmotes(idList)
+		if len(missing) > 0 {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": fmt.Sprintf("Emotes not found: %s", strings.Join(missing, ", "))})
+			return
+		}
+	}
+
+	emotes, _ = seventv.FilterVisible(emotes, seventv.NewVisibilityPolicy(config.LoadConfig()).ForLookup())
+	if len(emotes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "No emotes found for the given source"})
+		return
+	}
+
+	sheet, err := seventv.BuildSpriteSheet(emotes, folder, source, size)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": fmt.Sprintf("Could not build sprite sheet: %v", err)})
+		return
+	}
+
+	resp.Fingerprint = sheet.Fingerprint
+	resp.ImageURL = storage.PresentURL(storage.PublicURL(folder, sheet.ImageBlob))
+	resp.AtlasURL = storage.PresentURL(storage.PublicURL(folder, sheet.AtlasBlob))
+	resp.Generated = sheet.Generated
+	resp.Atlas = sheet.Atlas
+	resp.Failures = sheet.Failures
+	resp.ProcessingTime = time.Since(start).Seconds()
+	c.JSON(http.StatusOK, resp)
+}
@@ -2,15 +2,15 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
-	"hash/crc32"
+	"log"
 	"net/http"
-	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"gokeki/config"
 	"gokeki/models"
 	"gokeki/services/cache"
 	"gokeki/services/storage"
@@ -31,115 +31,190 @@ func getStorageLimiter() gin.HandlerFunc {
 	return mgin.NewMiddleware(l)
 }
 
+// The storage listing endpoints serve any folder of the STORAGE_FOLDERS
+// allowlist. /trending-emotes and /emote-api are kept as aliases of the
+// generic /folders/:folder endpoint.
+
 func getTrendingEmotesFromStorage(c *gin.Context) {
-	start := time.Now()
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if page < 1 {
-		page = 1
-	}
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	if limit < 1 {
-		limit = 20
+	listAllowedFolder(c, trendingFolder, "No trending emotes found in storage")
+}
+
+func getEmotesFromStorage(c *gin.Context) {
+	listAllowedFolder(c, emoteFolder, "No emotes found in storage")
+}
+
+func listStorageFolders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"folders": config.LoadConfig().StorageFolders,
+	})
+}
+
+func getFolderFromStorage(c *gin.Context) {
+	folder := c.Param("folder")
+	listAllowedFolder(c, folder, fmt.Sprintf("No emotes found in storage folder %s", folder))
+}
+
+// listAllowedFolder lists a folder if it is in the allowlist, so the aliases
+// can't expose a folder that was removed from STORAGE_FOLDERS.
+func listAllowedFolder(c *gin.Context, folder string, emptyMessage string) {
+	if !storageFolderAllowed(folder) {
+		respondUnknownFolder(c, folder)
+		return
 	}
-	if limit > 100 {
-		limit = 100
+	listStoredEmotes(c, folder, emptyMessage)
+}
+
+func storageFolderAllowed(folder string) bool {
+	for _, f := range config.LoadConfig().StorageFolders {
+		if f == folder {
+			return true
+		}
 	}
+	return false
+}
+
+func respondUnknownFolder(c *gin.Context, folder string) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"success": false,
+		"message": fmt.Sprintf("Unknown storage folder %q", folder),
+		"folders": config.LoadConfig().StorageFolders,
+	})
+}
+
+// listStoredEmotes serves one page of the blobs of a folder. Clients either
+// follow the opaque `cursor` returned as nextCursor, or ask for a `page`
+// number, which is resolved through the page index kept in Redis. Totals come
+// from the folder index, so the folder isn't listed on every request.
+func listStoredEmotes(c *gin.Context, folder string, emptyMessage string) {
+	start := time.Now()
+	page, limit := parsePageParams(c)
+	prefix := folder + "/"
 
 	if !storage.AzureStorageAvailable() {
-		c.JSON(http.StatusOK, models.SearchResponse{
-			Success:        false,
-			TotalFound:     0,
-			Emotes:         []models.EmoteResponse{},
-			Message:        "Azure Storage is not properly configured or unavailable",
-			ProcessingTime: time.Since(start).Seconds(),
-			Page:           page,
-			TotalPages:     0,
-			ResultsPerPage: limit,
-			HasNextPage:    false,
-		})
+		respondStorageUnavailable(c, start, page, limit)
 		return
 	}
 
-	prefix := "trending_emotes/"
-	blobList, err := storage.ListBlobsWithPrefix(prefix)
+	totalFound, err := storage.IndexSize(prefix)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.SearchResponse{
-			Success:        false,
-			Message:        fmt.Sprintf("Error accessing Azure Storage: %v", err),
-			ProcessingTime: time.Since(start).Seconds(),
-		})
+		log.Printf("⚠️  Could not count %s: %v", prefix, err)
+	}
+	totalPages := (totalFound + limit - 1) / limit
+
+	var marker string
+	if cursor := c.Query("cursor"); cursor != "" {
+		marker, err = storage.DecodeCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.SearchResponse{
+				Success:        false,
+				Emotes:         []models.EmoteResponse{},
+				Message:        "Invalid cursor",
+				ProcessingTime: time.Since(start).Seconds(),
+			})
+			return
+		}
+		page = 0 // Cursor based pages have no number
+	} else {
+		var found bool
+		marker, found, err = storage.PageMarker(prefix, limit, page)
+		if err != nil {
+			respondStorageError(c, start, err)
+			return
+		}
+		if !found {
+			c.JSON(http.StatusOK, models.SearchResponse{
+				Success:        false,
+				TotalFound:     totalFound,
+				Emotes:         []models.EmoteResponse{},
+				Message:        fmt.Sprintf("Page %d exceeds available pages (total: %d)", page, totalPages),
+				ProcessingTime: time.Since(start).Seconds(),
+				Page:           page,
+				TotalPages:     totalPages,
+				ResultsPerPage: limit,
+				HasNextPage:    false,
+			})
+			return
+		}
+	}
+
+	blobList, next, err := storage.ListBlobsPage(prefix, marker, limit)
+	if err != nil {
+		respondStorageError(c, start, err)
 		return
 	}
+	if page > 0 {
+		storage.RememberPageMarker(prefix, limit, page, next)
+	}
 
-	sort.Slice(blobList, func(i, j int) bool {
-		if blobList[i].Name == nil || blobList[j].Name == nil {
-			return false
+	processed := []models.EmoteResponse{}
+	for _, b := range blobList {
+		if entry, ok := storage.EntryFromBlob(b, prefix); ok {
+			processed = append(processed, entry.EmoteResponse())
 		}
-		return *blobList[i].Name < *blobList[j].Name
-	})
+	}
 
-	totalFound := len(blobList)
-	totalPages := (totalFound + limit - 1) / limit
+	resp := models.SearchResponse{
+		Success:        true,
+		TotalFound:     totalFound,
+		Emotes:         processed,
+		ProcessingTime: time.Since(start).Seconds(),
+		Page:           page,
+		TotalPages:     totalPages,
+		ResultsPerPage: limit,
+		HasNextPage:    next != "",
+		NextCursor:     storage.EncodeCursor(next),
+	}
+	if len(processed) == 0 && marker == "" {
+		resp.Message = emptyMessage
+	}
+	presentEmotes(resp.Emotes)
+	c.JSON(http.StatusOK, resp)
+}
 
-	if totalFound == 0 {
-		c.JSON(http.StatusOK, models.SearchResponse{
-			Success:        true,
-			TotalFound:     0,
-			Emotes:         []models.EmoteResponse{},
-			Message:        "No trending emotes found in storage",
-			ProcessingTime: time.Since(start).Seconds(),
-			Page:           page,
-			TotalPages:     0,
-			ResultsPerPage: limit,
-			HasNextPage:    false,
-		})
+// searchStoredEmotes searches the mirrored emotes of a folder using the folder
+// index, without contacting 7TV. Filters: q (name substring), prefix (name
+// prefix), animated (true/false), mime (webp, gif, ... or a mime type), since
+// and until (RFC 3339 or YYYY-MM-DD, on the mirror date).
+func searchStoredEmotes(c *gin.Context) {
+	start := time.Now()
+	page, limit := parsePageParams(c)
+
+	folder := c.DefaultQuery("folder", emoteFolder)
+	if !storageFolderAllowed(folder) {
+		respondUnknownFolder(c, folder)
 		return
 	}
 
-	startIdx := (page - 1) * limit
-	if startIdx >= totalFound {
-		c.JSON(http.StatusOK, models.SearchResponse{
-			Success:        false,
-			TotalFound:     totalFound,
-			Emotes:         []models.EmoteResponse{},
-			Message:        fmt.Sprintf("Page %d exceeds available pages (total: %d)", page, totalPages),
-			ProcessingTime: time.Since(start).Seconds(),
-			Page:           page,
-			TotalPages:     totalPages,
-			ResultsPerPage: limit,
-			HasNextPage:    false,
+	filter, err := parseIndexFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
 		})
 		return
 	}
 
-	endIdx := startIdx + limit
-	if endIdx > totalFound {
-		endIdx = totalFound
+	if !storage.AzureStorageAvailable() {
+		respondStorageUnavailable(c, start, page, limit)
+		return
+	}
+
+	entries, err := storage.SearchIndex(folder+"/", filter)
+	if err != nil {
+		respondStorageError(c, start, err)
+		return
 	}
-	pageBlobs := blobList[startIdx:endIdx]
+
+	totalFound := len(entries)
+	startIdx, endIdx, totalPages := pageBounds(totalFound, page, limit)
 
 	processed := []models.EmoteResponse{}
-	for _, b := range pageBlobs {
-		if b.Name == nil {
-			continue
-		}
-		fileName := strings.TrimPrefix(*b.Name, prefix)
-		if fileName == "" || strings.HasSuffix(fileName, "/") {
-			continue
-		}
-		blobURL := fmt.Sprintf("%s/%s", storage.ContainerURL(), *b.Name)
-		emoteName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-		hashValue := crc32.ChecksumIEEE([]byte(*b.Name))
-		emoteID := fmt.Sprintf("storage_%d", hashValue%10000000)
-		processed = append(processed, models.EmoteResponse{
-			FileName:  fileName,
-			URL:       blobURL,
-			EmoteID:   emoteID,
-			EmoteName: emoteName,
-		})
+	for _, e := range entries[startIdx:endIdx] {
+		processed = append(processed, e.EmoteResponse())
 	}
 
-	c.JSON(http.StatusOK, models.SearchResponse{
+	resp := models.SearchResponse{
 		Success:        true,
 		TotalFound:     totalFound,
 		Emotes:         processed,
@@ -148,125 +223,127 @@ func getTrendingEmotesFromStorage(c *gin.Context) {
 		TotalPages:     totalPages,
 		ResultsPerPage: limit,
 		HasNextPage:    page < totalPages,
-	})
+	}
+	if totalFound == 0 {
+		resp.Message = "No stored emotes match the given filters"
+	}
+	presentEmotes(resp.Emotes)
+	c.JSON(http.StatusOK, resp)
 }
 
-func getEmotesFromStorage(c *gin.Context) {
-	start := time.Now()
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if page < 1 {
-		page = 1
+func parseIndexFilter(c *gin.Context) (storage.IndexFilter, error) {
+	filter := storage.IndexFilter{
+		Query:  c.Query("q"),
+		Prefix: c.Query("prefix"),
 	}
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	if limit < 1 {
-		limit = 20
+
+	animated, err := optionalBoolQuery(c, "animated")
+	if err != nil {
+		return filter, err
 	}
-	if limit > 100 {
-		limit = 100
+	filter.Animated = animated
+
+	if mime := strings.ToLower(c.Query("mime")); mime != "" {
+		if !strings.Contains(mime, "/") {
+			mime = "image/" + mime
+		}
+		filter.Mime = mime
 	}
 
-	if !storage.AzureStorageAvailable() {
-		c.JSON(http.StatusOK, models.SearchResponse{
-			Success:        false,
-			TotalFound:     0,
-			Emotes:         []models.EmoteResponse{},
-			Message:        "Azure Storage is not properly configured or unavailable",
-			ProcessingTime: time.Since(start).Seconds(),
-			Page:           page,
-			TotalPages:     0,
-			ResultsPerPage: limit,
-			HasNextPage:    false,
-		})
-		return
+	if filter.Since, err = parseDateQuery(c, "since"); err != nil {
+		return filter, err
 	}
+	if filter.Until, err = parseDateQuery(c, "until"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
 
-	prefix := "emote_api/"
-	blobList, err := storage.ListBlobsWithPrefix(prefix)
+// parseDateQuery accepts RFC 3339 timestamps or plain dates. A plain "until"
+// date includes the whole day.
+func parseDateQuery(c *gin.Context, name string) (time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.SearchResponse{
-			Success:        false,
-			Message:        fmt.Sprintf("Error accessing Azure Storage: %v", err),
-			ProcessingTime: time.Since(start).Seconds(),
-		})
-		return
+		return time.Time{}, fmt.Errorf("invalid %s %q. Use RFC 3339 or YYYY-MM-DD", name, raw)
+	}
+	if name == "until" {
+		t = t.Add(24*time.Hour - time.Nanosecond)
 	}
+	return t, nil
+}
 
-	sort.Slice(blobList, func(i, j int) bool {
-		if blobList[i].Name == nil || blobList[j].Name == nil {
-			return false
-		}
-		return *blobList[i].Name < *blobList[j].Name
-	})
+// maxDuplicateThreshold is the largest accepted Hamming distance: beyond half
+// of the 64 bits, hashes are no more alike than unrelated images.
+const maxDuplicateThreshold = 32
 
-	totalFound := len(blobList)
-	totalPages := (totalFound + limit - 1) / limit
+// findStoredDuplicates returns clusters of near-duplicate emotes in a folder,
+// comparing the perceptual hashes of their first frames. With emote_id it
+// returns the emotes similar to that one. threshold is the largest Hamming
+// distance (0-32 bits of 64) still considered a duplicate.
+func findStoredDuplicates(c *gin.Context) {
+	start := time.Now()
 
-	if totalFound == 0 {
-		c.JSON(http.StatusOK, models.SearchResponse{
-			Success:        true,
-			TotalFound:     0,
-			Emotes:         []models.EmoteResponse{},
-			Message:        "No emotes found in storage",
-			ProcessingTime: time.Since(start).Seconds(),
-			Page:           page,
-			TotalPages:     0,
-			ResultsPerPage: limit,
-			HasNextPage:    false,
-		})
+	folder := c.DefaultQuery("folder", emoteFolder)
+	if !storageFolderAllowed(folder) {
+		respondUnknownFolder(c, folder)
 		return
 	}
 
-	startIdx := (page - 1) * limit
-	if startIdx >= totalFound {
-		c.JSON(http.StatusOK, models.SearchResponse{
-			Success:        false,
-			TotalFound:     totalFound,
-			Emotes:         []models.EmoteResponse{},
-			Message:        fmt.Sprintf("Page %d exceeds available pages (total: %d)", page, totalPages),
-			ProcessingTime: time.Since(start).Seconds(),
-			Page:           page,
-			TotalPages:     totalPages,
-			ResultsPerPage: limit,
-			HasNextPage:    false,
+	threshold, err := strconv.Atoi(c.DefaultQuery("threshold", "10"))
+	if err != nil || threshold < 0 || threshold > maxDuplicateThreshold {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("threshold must be a number between 0 and %d", maxDuplicateThreshold),
 		})
 		return
 	}
 
-	endIdx := startIdx + limit
-	if endIdx > totalFound {
-		endIdx = totalFound
+	if !storage.AzureStorageAvailable() {
+		respondStorageUnavailable(c, start, 0, 0)
+		return
 	}
-	pageBlobs := blobList[startIdx:endIdx]
 
-	processed := []models.EmoteResponse{}
-	for _, b := range pageBlobs {
-		if b.Name == nil {
-			continue
-		}
-		fileName := strings.TrimPrefix(*b.Name, prefix)
-		if fileName == "" || strings.HasSuffix(fileName, "/") {
-			continue
-		}
-		blobURL := fmt.Sprintf("%s/%s", storage.ContainerURL(), *b.Name)
-		emoteName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-		hashValue := crc32.ChecksumIEEE([]byte(*b.Name))
-		emoteID := fmt.Sprintf("storage_%d", hashValue%10000000)
-		processed = append(processed, models.EmoteResponse{
-			FileName:  fileName,
-			URL:       blobURL,
-			EmoteID:   emoteID,
-			EmoteName: emoteName,
+	emoteID := c.Query("emote_id")
+	clusters, unhashed, err := storage.FindDuplicates(folder+"/", emoteID, threshold)
+	if errors.Is(err, storage.ErrNotHashed) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("Emote %s has no perceptual hash in storage folder %s", emoteID, folder),
 		})
+		return
+	}
+	if err != nil {
+		respondStorageError(c, start, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, models.SearchResponse{
+	for _, cluster := range clusters {
+		for i := range cluster.Emotes {
+			presentEmote(&cluster.Emotes[i].EmoteResponse)
+		}
+	}
+
+	resp := models.DuplicatesResponse{
 		Success:        true,
-		TotalFound:     totalFound,
-		Emotes:         processed,
+		Folder:         folder,
+		EmoteID:        emoteID,
+		Threshold:      threshold,
+		TotalClusters:  len(clusters),
+		Clusters:       clusters,
+		Unhashed:       unhashed,
 		ProcessingTime: time.Since(start).Seconds(),
-		Page:           page,
-		TotalPages:     totalPages,
-		ResultsPerPage: limit,
-		HasNextPage:    page < totalPages,
-	})
+	}
+	if emoteID == "" && len(clusters) == 0 {
+		resp.Message = "No near-duplicate emotes found"
+	} else if emoteID != "" && len(clusters[0].Emotes) == 1 {
+		resp.Message = fmt.Sprintf("No emotes similar to %s found", emoteID)
+	}
+	c.JSON(http.StatusOK, resp)
 }
@@ -35,7 +35,15 @@ func trendingEmotes(c *gin.Context) {
 	if periodStr == "" {
 		periodStr = string(models.Weekly)
 	}
-	period := models.TrendingPeriod(periodStr)
+	period, err := models.ParseTrendingPeriod(periodStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":          false,
+			"message":          err.Error(),
+			"supportedPeriods": models.SupportedPeriods,
+		})
+		return
+	}
 
 	limitStr := c.Query("limit")
 	limit, _ := strconv.Atoi(limitStr)
@@ -70,15 +78,8 @@ func trendingEmotes(c *gin.Context) {
 	}
 
 	// Validar emote_type
-	var animationFilter seventv.AnimationFilter
-	switch emoteType {
-	case "animated":
-		animationFilter = seventv.AnimatedOnly
-	case "static":
-		animationFilter = seventv.StaticOnly
-	case "all":
-		animationFilter = seventv.AllEmotes
-	default:
+	animationFilter, err := seventv.ParseAnimationFilter(emoteType)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "Invalid emote_type. Use 'all', 'animated', or 'static'",
@@ -86,24 +87,65 @@ func trendingEmotes(c *gin.Context) {
 		return
 	}
 
+	maxScale, err := optionalIntQuery(c, "max_scale")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	maxWidth, err := optionalIntQuery(c, "max_width")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	policy, err := seventv.ParseImageSelectionPolicy(seventv.SplitFormats(c.Query("formats")), maxScale, maxWidth, c.Query("variant"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var overrides [4]*bool
+	for i, name := range []string{"show_deleted", "show_private", "show_unlisted", "show_pending"} {
+		overrides[i], err = optionalBoolQuery(c, name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	visibility := seventv.NewVisibilityPolicy(config.LoadConfig()).
+		WithOverrides(overrides[0], overrides[1], overrides[2], overrides[3])
+
 	fetchLimit := page * limit
 	if fetchLimit > 300 {
 		fetchLimit = 300
 	}
 
-	cacheKey := cache.GetTrendingCacheKey(string(period), limit, page, emoteType)
+	cacheKey := cache.GetTrendingCacheKey(string(period), limit, page, emoteType, policy.Key(), visibility.Key())
 	cached, err := cache.GetFromCache(cacheKey)
 	if err == nil && cached != nil {
 		var resp models.SearchResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			resp.ProcessingTime = time.Since(start).Seconds()
 			resp.Cached = true
+			presentEmotes(resp.Emotes)
 			c.JSON(http.StatusOK, resp)
 			return
 		}
 	}
 
-	emotes := seventv.Fetch7TVTrendingEmotesAdvanced(string(period), fetchLimit, animationFilter)
+	emotes := seventv.Fetch7TVTrendingEmotesAdvanced(period, fetchLimit, animationFilter)
+	emotes, stats := seventv.FilterVisible(emotes, visibility)
 	if len(emotes) == 0 {
 		resp := models.SearchResponse{
 			Success:        true,
@@ -115,23 +157,20 @@ func trendingEmotes(c *gin.Context) {
 			TotalPages:     0,
 			ResultsPerPage: limit,
 			HasNextPage:    false,
+			Filtered:       filteredStats(stats),
 		}
 		cache.SaveToCache(cacheKey, resp, config.LoadConfig().TrendingCacheTTL)
 		c.JSON(http.StatusOK, resp)
 		return
 	}
 
+	// Filtering can leave fewer emotes than the requested page needs, in which
+	// case the page is empty
 	totalFound := len(emotes)
-	totalPages := (totalFound + limit - 1) / limit
-
-	startIdx := (page - 1) * limit
-	endIdx := startIdx + limit
-	if endIdx > totalFound {
-		endIdx = totalFound
-	}
+	startIdx, endIdx, totalPages := pageBounds(totalFound, page, limit)
 	pageEmotes := emotes[startIdx:endIdx]
 
-	processed := seventv.ProcessEmotesBatch(pageEmotes, "trending_emotes")
+	processed, failures := seventv.ProcessEmotesBatch(pageEmotes, trendingFolder, policy)
 
 	resp := models.SearchResponse{
 		Success:        true,
@@ -142,7 +181,52 @@ func trendingEmotes(c *gin.Context) {
 		TotalPages:     totalPages,
 		ResultsPerPage: limit,
 		HasNextPage:    page < totalPages,
+		Filtered:       filteredStats(stats),
+		Failures:       failures,
+	}
+	// Failed emotes are retried on the next request instead of being cached
+	if len(failures) == 0 {
+		cache.SaveToCache(cacheKey, resp, config.LoadConfig().TrendingCacheTTL)
 	}
-	cache.SaveToCache(cacheKey, resp, config.LoadConfig().TrendingCacheTTL)
+	presentEmotes(resp.Emotes)
 	c.JSON(http.StatusOK, resp)
 }
+
+// optionalBoolQuery parses an optional boolean query parameter, returning nil
+// when it is absent.
+func optionalBoolQuery(c *gin.Context, name string) (*bool, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q. Use 'true' or 'false'", name, raw)
+	}
+	return &value, nil
+}
+
+// periodDescriptions documents each supported trending period.
+var periodDescriptions = map[models.TrendingPeriod]string{
+	models.Daily:   "Most used emotes over the last day",
+	models.Weekly:  "Most used emotes over the last week",
+	models.Monthly: "Most used emotes over the last month",
+	models.AllTime: "Most popular emotes of all time",
+}
+
+func trendingPeriods(c *gin.Context) {
+	periods := make([]gin.H, 0, len(models.SupportedPeriods))
+	for _, p := range models.SupportedPeriods {
+		periods = append(periods, gin.H{
+			"period":      p,
+			"description": periodDescriptions[p],
+			"sortBy":      seventv.SortForPeriod(p),
+			"ranking":     seventv.RankingForPeriod(p),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"default": models.Weekly,
+		"periods": periods,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"gokeki/config"
@@ -33,12 +34,21 @@ func InitRedis(cfg *config.Config) {
 	}
 }
 
-func GetCacheKey(query string, limit int, animatedOnly bool) string {
-	return fmt.Sprintf("emote_search:%s:%d:%t", query, limit, animatedOnly)
+// GetCacheKey builds the search cache key. Extra parts (e.g. the image
+// selection policy) are appended so that different variants don't collide.
+func GetCacheKey(query string, limit int, animatedOnly bool, extra ...string) string {
+	return withExtra(fmt.Sprintf("emote_search:%s:%d:%t", query, limit, animatedOnly), extra)
 }
 
-func GetTrendingCacheKey(period string, limit int, page int, emoteType string) string {
-	return fmt.Sprintf("trending:%s:%d:%d:%s", period, limit, page, emoteType)
+func GetTrendingCacheKey(period string, limit int, page int, emoteType string, extra ...string) string {
+	return withExtra(fmt.Sprintf("trending:%s:%d:%d:%s", period, limit, page, emoteType), extra)
+}
+
+func withExtra(key string, extra []string) string {
+	if len(extra) == 0 {
+		return key
+	}
+	return key + ":" + strings.Join(extra, ":")
 }
 
 // GetTrendingCacheKeyLegacy mantiene compatibilidad con animated_only boolean
@@ -0,0 +1,83 @@
+// services/seventv/download.go
+package seventv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// spooledImage is a downloaded image kept in a temporary file. The content
+// address of an image is its hash, which is only known once the whole body
+// was read, so images are spooled to disk instead of being held in memory and
+// then streamed from there into storage.
+type spooledImage struct {
+	file *os.File
+	Size int64
+	Hash string
+}
+
+// downloadImage streams url into a temporary file, hashing it on the way.
+// Bodies larger than maxSize bytes, or shorter than their Content-Length, are
+// rejected.
+func downloadImage(url string, maxSize int64) (*spooledImage, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("image too large (%d bytes, limit %d)", resp.ContentLength, maxSize)
+	}
+
+	file, err := os.CreateTemp("", "emote-*")
+	if err != nil {
+		return nil, err
+	}
+	img := &spooledImage{file: file}
+
+	// Read one byte past the limit to tell a body of exactly maxSize bytes
+	// from a larger one.
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(file, hasher), io.LimitReader(resp.Body, maxSize+1))
+	switch {
+	case err != nil:
+		img.Close()
+		return nil, fmt.Errorf("truncated download after %d bytes: %v", n, err)
+	case n > maxSize:
+		img.Close()
+		return nil, fmt.Errorf("image too large (more than %d bytes)", maxSize)
+	case resp.ContentLength >= 0 && n != resp.ContentLength:
+		img.Close()
+		return nil, fmt.Errorf("truncated download (got %d of %d bytes)", n, resp.ContentLength)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		img.Close()
+		return nil, err
+	}
+	img.Size = n
+	img.Hash = hex.EncodeToString(hasher.Sum(nil))
+	return img, nil
+}
+
+func (s *spooledImage) Read(p []byte) (int, error) {
+	return s.file.Read(p)
+}
+
+func (s *spooledImage) ReadAt(p []byte, off int64) (int, error) {
+	return s.file.ReadAt(p, off)
+}
+
+// Close removes the temporary file.
+func (s *spooledImage) Close() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
@@ -0,0 +1,108 @@
+// services/seventv/download_test.go
+package seventv
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gokeki/services/storage"
+)
+
+func TestDownloadImage(t *testing.T) {
+	body := bytes.Repeat([]byte("emote"), 20) // 100 bytes
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		maxSize int64
+		wantErr string
+	}{
+		{
+			name:    "complete body",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write(body) },
+			maxSize: 1000,
+		},
+		{
+			name:    "exactly the limit",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write(body) },
+			maxSize: 100,
+		},
+		{
+			name: "chunked body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.(http.Flusher).Flush()
+				w.Write(body)
+			},
+			maxSize: 1000,
+		},
+		{
+			name:    "content length over the limit",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write(body) },
+			maxSize: 99,
+			wantErr: "image too large (100 bytes",
+		},
+		{
+			name: "chunked body over the limit",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.(http.Flusher).Flush()
+				w.Write(body)
+			},
+			maxSize: 99,
+			wantErr: "image too large (more than 99 bytes)",
+		},
+		{
+			name: "body shorter than content length",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)+50))
+				w.Write(body)
+			},
+			maxSize: 1000,
+			wantErr: "truncated download",
+		},
+		{
+			name:    "error status",
+			handler: func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) },
+			maxSize: 1000,
+			wantErr: "HTTP 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			img, err := downloadImage(server.URL, tt.maxSize)
+			if tt.wantErr != "" {
+				if err == nil {
+					img.Close()
+					t.Fatalf("expected an error containing %q", tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer img.Close()
+
+			data, err := io.ReadAll(img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, body) || img.Size != int64(len(body)) {
+				t.Errorf("spooled %d bytes (size %d), want %d", len(data), img.Size, len(body))
+			}
+			if img.Hash != storage.ContentHash(body) {
+				t.Errorf("hash = %s, want %s", img.Hash, storage.ContentHash(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// services/seventv/emote.go
+package seventv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"gokeki/config"
+	"gokeki/models"
+	"gokeki/services/cache"
+	"gokeki/services/storage"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const gqlURL = "https://api.7tv.app/v4/gql"
+
+// doGQL posts a GraphQL operation to 7TV and decodes the response into out.
+func doGQL(operationName, query string, variables map[string]interface{}, out interface{}) error {
+	payload := map[string]interface{}{
+		"operationName": operationName,
+		"query":         query,
+		"variables":     variables,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", gqlURL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("7TV API returned %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+const emoteQuery = `
+query Emote($id: Id!) {
+  emotes {
+    emote(id: $id) {
+      id
+      defaultName
+      owner {
+        id
+        mainConnection {
+          platformDisplayName
+        }
+        highestRoleColor {
+          hex
+        }
+      }
+      deleted
+      flags {
+        defaultZeroWidth
+        private
+        publicListed
+      }
+      imagesPending
+      images {
+        url
+        mime
+        size
+        scale
+        width
+        height
+        frameCount
+      }
+    }
+  }
+}
+`
+
+// Fetch7TVEmote fetches a single emote by ID. It returns nil when the emote
+// doesn't exist or 7TV can't be reached.
+func Fetch7TVEmote(id string) *Emote {
+	var resp struct {
+		Data struct {
+			Emotes struct {
+				Emote *Emote `json:"emote"`
+			} `json:"emotes"`
+		} `json:"data"`
+	}
+	if err := doGQL("Emote", emoteQuery, map[string]interface{}{"id": id}, &resp); err != nil {
+		log.Printf("Error fetching emote %s: %v", id, err)
+		return nil
+	}
+	return resp.Data.Emotes.Emote
+}
+
+// GetEmote returns an emote by ID, cached in Redis for CACHE_TTL.
+func GetEmote(id string) *Emote {
+	key := "emote:" + id
+	if cached, err := cache.GetFromCache(key); err == nil && cached != nil {
+		var e Emote
+		if err := json.Unmarshal(cached, &e); err == nil {
+			return &e
+		}
+	}
+
+	e := Fetch7TVEmote(id)
+	if e != nil {
+		cache.SaveToCache(key, e, config.LoadConfig().CacheTTL)
+	}
+	return e
+}
+
+// GetEmotes returns the emotes with the given IDs, in order, and the IDs that
+// were not found.
+func GetEmotes(ids []string) ([]Emote, []string) {
+	g, _ := errgroup.WithContext(context.Background())
+	g.SetLimit(10)
+
+	found := make([]*Emote, len(ids))
+	for i, id := range ids {
+		i, id := i, id
+		g.Go(func() error {
+			found[i] = GetEmote(id)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	var emotes []Emote
+	var missing []string
+	for i, e := range found {
+		if e == nil {
+			missing = append(missing, ids[i])
+			continue
+		}
+		emotes = append(emotes, *e)
+	}
+	return emotes, missing
+}
+
+// MirrorEmote returns the image of e selected by policy as mirrored in folder.
+// Images already mirrored are answered from their reference without
+// contacting 7TV, with the previews, perceptual hash and animation details
+// recorded in its metadata; missing ones are downloaded and stored first. It
+// returns nil without error when no image matches the policy.
+func MirrorEmote(e Emote, folder string, policy ImageSelectionPolicy) (*models.EmoteResponse, error) {
+	img := policy.Select(e.Images)
+	if img == nil {
+		return nil, nil
+	}
+
+	fileName := mirrorFileName(e, img)
+	ref, found, err := storage.GetReference(folder, fileName)
+	if err != nil {
+		log.Printf("Error reading reference for emote %s: %v", e.DefaultName, err)
+	}
+	if found {
+		resp := emoteResponse(e, img, folder, fileName, ref.ContentBlob, ref.Hash)
+		if ref.Meta.Width > 0 && ref.Meta.Height > 0 {
+			resp.Width, resp.Height = ref.Meta.Width, ref.Meta.Height
+		}
+		resp.PHash = ref.Meta.PHash
+		resp.Animation = ref.Meta.Animation()
+		if ref.Meta.Preview != "" {
+			resp.PreviewURL = storage.PublicURL(folder, ref.Meta.Preview)
+		}
+		if ref.Meta.Thumbnail != "" {
+			resp.ThumbnailURL = storage.PublicURL(folder, ref.Meta.Thumbnail)
+		}
+		return resp, nil
+	}
+
+	return processEmote(e, folder, policy)
+}
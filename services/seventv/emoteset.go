@@ -0,0 +1,138 @@
+// services/seventv/emoteset.go
+package seventv
+
+import (
+	"encoding/json"
+	"log"
+
+	"gokeki/config"
+	"gokeki/services/cache"
+)
+
+// EmoteSet is a 7TV emote set with the emotes it contains.
+type EmoteSet struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Emotes []EmoteSetEmote `json:"emotes"`
+}
+
+// EmoteSetEmote is an emote of a set under the alias it has in the set.
+type EmoteSetEmote struct {
+	Alias string `json:"alias"`
+	Emote *Emote `json:"emote"`
+}
+
+// maxSetEmotes is the page size used to fetch a whole emote set in one
+// request; 7TV sets are capped well below it.
+const maxSetEmotes = 1000
+
+const emoteSetQuery = `
+query EmoteSet($id: Id!, $perPage: Int!) {
+  emoteSets {
+    emoteSet(id: $id) {
+      id
+      name
+      emotes(page: 1, perPage: $perPage) {
+        items {
+          alias
+          emote {
+            id
+            defaultName
+            owner {
+              id
+              mainConnection {
+                platformDisplayName
+              }
+              highestRoleColor {
+                hex
+              }
+            }
+            deleted
+            flags {
+              defaultZeroWidth
+              private
+              publicListed
+            }
+            imagesPending
+            images {
+              url
+              mime
+              size
+              scale
+              width
+              height
+              frameCount
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// Fetch7TVEmoteSet fetches an emote set by ID. It returns nil when the set
+// doesn't exist or 7TV can't be reached.
+func Fetch7TVEmoteSet(id string) *EmoteSet {
+	var resp struct {
+		Data struct {
+			EmoteSets struct {
+				EmoteSet *struct {
+					ID     string `json:"id"`
+					Name   string `json:"name"`
+					Emotes struct {
+						Items []EmoteSetEmote `json:"items"`
+					} `json:"emotes"`
+				} `json:"emoteSet"`
+			} `json:"emoteSets"`
+		} `json:"data"`
+	}
+	vars := map[string]interface{}{"id": id, "perPage": maxSetEmotes}
+	if err := doGQL("EmoteSet", emoteSetQuery, vars, &resp); err != nil {
+		log.Printf("Error fetching emote set %s: %v", id, err)
+		return nil
+	}
+
+	s := resp.Data.EmoteSets.EmoteSet
+	if s == nil {
+		return nil
+	}
+	set := &EmoteSet{ID: s.ID, Name: s.Name}
+	for _, item := range s.Emotes.Items {
+		if item.Emote != nil {
+			set.Emotes = append(set.Emotes, item)
+		}
+	}
+	return set
+}
+
+// GetEmoteSet returns an emote set by ID, cached in Redis for CACHE_TTL.
+func GetEmoteSet(id string) *EmoteSet {
+	key := "emote_set:" + id
+	if cached, err := cache.GetFromCache(key); err == nil && cached != nil {
+		var s EmoteSet
+		if err := json.Unmarshal(cached, &s); err == nil {
+			return &s
+		}
+	}
+
+	s := Fetch7TVEmoteSet(id)
+	if s != nil {
+		cache.SaveToCache(key, s, config.LoadConfig().CacheTTL)
+	}
+	return s
+}
+
+// EmoteList returns the emotes of the set in set order, named by their alias
+// in the set.
+func (s *EmoteSet) EmoteList() []Emote {
+	emotes := make([]Emote, 0, len(s.Emotes))
+	for _, item := range s.Emotes {
+		e := *item.Emote
+		if item.Alias != "" {
+			e.DefaultName = item.Alias
+		}
+		emotes = append(emotes, e)
+	}
+	return emotes
+}
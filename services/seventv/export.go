@@ -0,0 +1,206 @@
+// services/seventv/export.go
+package seventv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gokeki/models"
+	"gokeki/services/storage"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ExportPreset describes the files a platform accepts for custom emotes.
+// Limits are the ones documented by each platform; MaxFrames 0 means no
+// frame limit.
+type ExportPreset struct {
+	Name      string `json:"name"`
+	Platform  string `json:"platform"`
+	Sizes     []int  `json:"sizes"`
+	MaxBytes  int    `json:"maxBytes"`
+	MaxFrames int    `json:"maxFrames,omitempty"`
+}
+
+// exportPresets are the supported presets by name. Animated emotes are
+// exported as GIF and static ones as PNG on every platform.
+var exportPresets = map[string]ExportPreset{
+	"twitch":  {Name: "twitch", Platform: "Twitch", Sizes: []int{28, 56, 112}, MaxBytes: 1024 * 1024, MaxFrames: 60},
+	"discord": {Name: "discord", Platform: "Discord", Sizes: []int{128}, MaxBytes: 256 * 1024},
+	"slack":   {Name: "slack", Platform: "Slack", Sizes: []int{128}, MaxBytes: 128 * 1024},
+}
+
+// GetExportPreset returns a copy of the preset with the given name.
+func GetExportPreset(name string) (ExportPreset, bool) {
+	preset, ok := exportPresets[name]
+	preset.Sizes = append([]int(nil), preset.Sizes...)
+	return preset, ok
+}
+
+// ExportPresetNames returns the preset names, sorted.
+func ExportPresetNames() []string {
+	names := make([]string, 0, len(exportPresets))
+	for name := range exportPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExportEmotes produces the files of preset for every emote, mirroring the
+// source images into folder. Files are returned in emote and size order; each
+// one lists the constraints it doesn't meet.
+func ExportEmotes(emotes []Emote, folder string, preset ExportPreset) []models.ExportFile {
+	g, _ := errgroup.WithContext(context.Background())
+	g.SetLimit(4)
+
+	files := make([][]models.ExportFile, len(emotes))
+	for i, e := range emotes {
+		i, e := i, e
+		g.Go(func() error {
+			for _, size := range preset.Sizes {
+				files[i] = append(files[i], exportFile(e, folder, preset, size))
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	var result []models.ExportFile
+	for _, f := range files {
+		result = append(result, f...)
+	}
+	return result
+}
+
+// SafeFileName replaces the characters of an emote name that aren't safe in
+// file names on every platform.
+func SafeFileName(name string) string {
+	safe := []rune(name)
+	for i, r := range safe {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			safe[i] = '_'
+		}
+	}
+	if len(safe) == 0 {
+		return "emote"
+	}
+	return string(safe)
+}
+
+// exportColorSteps are the palette sizes tried, in order, to bring a file
+// under the preset's byte limit before frames are dropped.
+var exportColorSteps = []int{128, 64, 32}
+
+// minExportFrames is the fewest frames an animation is reduced to.
+const minExportFrames = 2
+
+// exportFile renders one emote at one size and checks it against the preset.
+// Animations are limited to MaxFrames, and files over MaxBytes are rendered
+// again with fewer colors and then fewer frames until they fit; every
+// reduction is listed in Adjustments.
+func exportFile(e Emote, folder string, preset ExportPreset, size int) models.ExportFile {
+	animated, sourceFrames := false, 1
+	if img := DefaultImageSelectionPolicy().Select(e.Images); img != nil {
+		animated, sourceFrames = img.FrameCount > 1, img.FrameCount
+	}
+	opts := ResizeOptions{Width: size, Height: size, Fit: FitContain, Format: "png"}
+	if animated {
+		opts.Format = "gif"
+		opts.MaxFrames = preset.MaxFrames
+	}
+
+	file := models.ExportFile{
+		EmoteID:   e.ID,
+		EmoteName: e.DefaultName,
+		Width:     size,
+		Height:    size,
+		Format:    opts.Format,
+	}
+
+	blobName, data, err := exportRendition(e, folder, opts)
+	frames := renditionFrames(data, opts)
+	for step := 0; err == nil && len(data) > preset.MaxBytes; step++ {
+		next, ok := reduceExport(opts, frames, step)
+		if !ok {
+			break
+		}
+		opts = next
+		blobName, data, err = exportRendition(e, folder, opts)
+		frames = renditionFrames(data, opts)
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotResizable) {
+			file.Issues = append(file.Issues, "no image of this emote can be converted")
+		} else {
+			file.Issues = append(file.Issues, fmt.Sprintf("conversion failed: %v", err))
+		}
+		return file
+	}
+
+	file.FileName = fmt.Sprintf("%s_%dx%d.%s", SafeFileName(e.DefaultName), size, size, opts.Format)
+	file.URL = storage.PublicURL(folder, blobName)
+	file.Bytes = len(data)
+	file.Frames = frames
+	if opts.Colors > 0 {
+		file.Adjustments = append(file.Adjustments, fmt.Sprintf("reduced to %d colors", opts.Colors))
+	}
+	if animated && frames > 1 && frames < sourceFrames {
+		file.Adjustments = append(file.Adjustments, fmt.Sprintf("reduced from %d to %d frames", sourceFrames, frames))
+	}
+
+	if file.Bytes > preset.MaxBytes {
+		file.Issues = append(file.Issues, fmt.Sprintf("file is %d bytes, %s allows %d", file.Bytes, preset.Platform, preset.MaxBytes))
+	}
+	if animated && file.Frames < 2 {
+		file.Issues = append(file.Issues, "animation is not available as GIF, only the first frame was exported")
+	}
+	if preset.MaxFrames > 0 && file.Frames > preset.MaxFrames {
+		file.Issues = append(file.Issues, fmt.Sprintf("animation has %d frames, %s allows %d", file.Frames, preset.Platform, preset.MaxFrames))
+	}
+	file.Compliant = len(file.Issues) == 0
+	return file
+}
+
+// exportRendition returns the blob and bytes of a rendition, creating it when
+// missing.
+func exportRendition(e Emote, folder string, opts ResizeOptions) (string, []byte, error) {
+	blobName, data, err := ResizeEmote(e, folder, opts)
+	if err == nil && data == nil {
+		var found bool
+		data, _, found, err = storage.DownloadBlob(blobName)
+		if err == nil && !found {
+			err = fmt.Errorf("rendition %s is missing from storage", blobName)
+		}
+	}
+	return blobName, data, err
+}
+
+// renditionFrames counts the frames of a rendition.
+func renditionFrames(data []byte, opts ResizeOptions) int {
+	if opts.Format == "gif" {
+		if anim, _, _, err := gifAnimation(bufio.NewReader(bytes.NewReader(data))); err == nil && anim.FrameCount > 0 {
+			return anim.FrameCount
+		}
+	}
+	return 1
+}
+
+// reduceExport returns the options of the next, smaller attempt: first fewer
+// colors, then half the frames of animations. The boolean is false when
+// nothing is left to reduce.
+func reduceExport(opts ResizeOptions, frames, step int) (ResizeOptions, bool) {
+	if step < len(exportColorSteps) {
+		opts.Colors = exportColorSteps[step]
+		return opts, true
+	}
+	if opts.Format != "gif" || frames <= minExportFrames {
+		return opts, false
+	}
+	opts.MaxFrames = max(minExportFrames, frames/2)
+	return opts, true
+}
@@ -0,0 +1,59 @@
+// services/seventv/export_test.go
+package seventv
+
+import "testing"
+
+func TestReduceExport(t *testing.T) {
+	gifOpts := ResizeOptions{Width: 112, Height: 112, Fit: FitContain, Format: "gif", MaxFrames: 60}
+	pngOpts := ResizeOptions{Width: 128, Height: 128, Fit: FitContain, Format: "png"}
+
+	tests := []struct {
+		name          string
+		opts          ResizeOptions
+		frames        int
+		step          int
+		wantOK        bool
+		wantColors    int
+		wantMaxFrames int
+	}{
+		{name: "first color step", opts: gifOpts, frames: 60, step: 0, wantOK: true, wantColors: 128, wantMaxFrames: 60},
+		{name: "last color step", opts: gifOpts, frames: 60, step: 2, wantOK: true, wantColors: 32, wantMaxFrames: 60},
+		{name: "halves frames", opts: gifOpts, frames: 60, step: 3, wantOK: true, wantMaxFrames: 30},
+		{name: "keeps minimum frames", opts: gifOpts, frames: 3, step: 4, wantOK: true, wantMaxFrames: minExportFrames},
+		{name: "nothing left in animation", opts: gifOpts, frames: minExportFrames, step: 5},
+		{name: "static colors", opts: pngOpts, frames: 1, step: 1, wantOK: true, wantColors: 64},
+		{name: "nothing left in static", opts: pngOpts, frames: 1, step: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := reduceExport(tt.opts, tt.frames, tt.step)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %t, want %t", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if tt.wantColors > 0 && got.Colors != tt.wantColors {
+				t.Errorf("colors = %d, want %d", got.Colors, tt.wantColors)
+			}
+			if got.MaxFrames != tt.wantMaxFrames {
+				t.Errorf("max frames = %d, want %d", got.MaxFrames, tt.wantMaxFrames)
+			}
+		})
+	}
+}
+
+func TestGetExportPresetCopy(t *testing.T) {
+	preset, ok := GetExportPreset("twitch")
+	if !ok {
+		t.Fatal("twitch preset not found")
+	}
+	preset.Sizes[0] = 1
+	if again, _ := GetExportPreset("twitch"); again.Sizes[0] == 1 {
+		t.Error("changing a returned preset changed the stored one")
+	}
+	if _, ok := GetExportPreset("myspace"); ok {
+		t.Error("unknown preset was found")
+	}
+}
@@ -0,0 +1,132 @@
+// services/seventv/filters.go
+package seventv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TagMatch controls how multiple tags are combined in a search.
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "ANY" // Al menos uno de los tags
+	TagMatchAll TagMatch = "ALL" // Todos los tags
+)
+
+const (
+	maxSearchTags   = 10
+	maxSearchTagLen = 64
+)
+
+// SearchFilters holds the optional search filters exposed by the API on top
+// of the free text query.
+type SearchFilters struct {
+	Tags          []string
+	TagMatch      TagMatch
+	ExactMatch    bool
+	ZeroWidth     *bool  // nil means no filter
+	Owner         string // Owner user ID or display name
+	CaseSensitive bool
+}
+
+// ParseSearchFilters validates and normalizes client supplied search filters.
+func ParseSearchFilters(tags []string, tagMatch string, exactMatch bool, zeroWidth *bool, owner string, caseSensitive bool) (SearchFilters, error) {
+	filters := SearchFilters{
+		TagMatch:      TagMatchAny,
+		ExactMatch:    exactMatch,
+		ZeroWidth:     zeroWidth,
+		Owner:         strings.TrimSpace(owner),
+		CaseSensitive: caseSensitive,
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxSearchTagLen {
+			return filters, fmt.Errorf("tag %q is too long (max %d characters)", tag, maxSearchTagLen)
+		}
+		seen[tag] = true
+		filters.Tags = append(filters.Tags, tag)
+	}
+	if len(filters.Tags) > maxSearchTags {
+		return filters, fmt.Errorf("too many tags (max %d)", maxSearchTags)
+	}
+	sort.Strings(filters.Tags)
+
+	switch TagMatch(strings.ToUpper(tagMatch)) {
+	case "", TagMatchAny:
+		filters.TagMatch = TagMatchAny
+	case TagMatchAll:
+		filters.TagMatch = TagMatchAll
+	default:
+		return filters, fmt.Errorf("invalid tag_match %q. Use 'any' or 'all'", tagMatch)
+	}
+
+	return filters, nil
+}
+
+// Key returns a compact, stable representation of the filters suitable for
+// cache keys.
+func (f SearchFilters) Key() string {
+	zeroWidth := "any"
+	if f.ZeroWidth != nil {
+		zeroWidth = strconv.FormatBool(*f.ZeroWidth)
+	}
+	return fmt.Sprintf("t=%s/%s:x=%t:zw=%s:o=%s:cs=%t",
+		strings.Join(f.Tags, ","), f.TagMatch, f.ExactMatch, zeroWidth, strings.ToLower(f.Owner), f.CaseSensitive)
+}
+
+// gqlFilters builds the value of the 7TV "filters" search argument.
+func (f SearchFilters) gqlFilters(animationFilter AnimationFilter) map[string]interface{} {
+	// AnimatedOnly => animated: true (solo animados)
+	// StaticOnly   => animated: false (solo estáticos)
+	// AllEmotes    => sin filtro de animación
+	filters := map[string]interface{}{}
+	switch animationFilter {
+	case AnimatedOnly:
+		filters["animated"] = true
+	case StaticOnly:
+		filters["animated"] = false
+	}
+	if f.ExactMatch {
+		filters["exactMatch"] = true
+	}
+	if f.ZeroWidth != nil {
+		filters["defaultZeroWidth"] = *f.ZeroWidth
+	}
+	return filters
+}
+
+// apply enforces the filters 7TV can't evaluate server side: the owner filter
+// and case sensitive matching of the query. The owner is matched case
+// insensitively, like Key normalizes it. Case sensitive matching only looks at
+// the emote name, so emotes 7TV matched through their tags are dropped.
+func (f SearchFilters) apply(items []Emote, query string) []Emote {
+	if f.Owner == "" && !f.CaseSensitive {
+		return items
+	}
+
+	filtered := items[:0]
+	for _, e := range items {
+		if f.Owner != "" && !strings.EqualFold(e.Owner.ID, f.Owner) &&
+			!strings.EqualFold(e.Owner.MainConnection.PlatformDisplayName, f.Owner) {
+			continue
+		}
+		if f.CaseSensitive {
+			if f.ExactMatch && e.DefaultName != query {
+				continue
+			}
+			if !f.ExactMatch && !strings.Contains(e.DefaultName, query) {
+				continue
+			}
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
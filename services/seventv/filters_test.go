@@ -0,0 +1,116 @@
+// services/seventv/filters_test.go
+package seventv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSearchFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		tagMatch string
+		owner    string
+		wantTags []string
+		wantMode TagMatch
+		wantErr  bool
+	}{
+		{name: "defaults", wantMode: TagMatchAny},
+		{name: "tags normalized", tags: []string{" Pepe", "sad", "PEPE", ""}, wantTags: []string{"pepe", "sad"}, wantMode: TagMatchAny},
+		{name: "tag match all", tags: []string{"cat"}, tagMatch: "all", wantTags: []string{"cat"}, wantMode: TagMatchAll},
+		{name: "tag match upper case", tagMatch: "ANY", wantMode: TagMatchAny},
+		{name: "owner trimmed", owner: "  forsen ", wantMode: TagMatchAny},
+		{name: "invalid tag match", tagMatch: "some", wantErr: true},
+		{name: "tag too long", tags: []string{strings.Repeat("a", maxSearchTagLen+1)}, wantErr: true},
+		{name: "too many tags", tags: strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSearchFilters(tt.tags, tt.tagMatch, false, nil, tt.owner, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(got.Tags, ",") != strings.Join(tt.wantTags, ",") {
+				t.Errorf("tags = %v, want %v", got.Tags, tt.wantTags)
+			}
+			if got.TagMatch != tt.wantMode {
+				t.Errorf("tag match = %s, want %s", got.TagMatch, tt.wantMode)
+			}
+			if got.Owner != strings.TrimSpace(tt.owner) {
+				t.Errorf("owner = %q, want %q", got.Owner, strings.TrimSpace(tt.owner))
+			}
+		})
+	}
+}
+
+func TestSearchFiltersKey(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name string
+		a, b SearchFilters
+		same bool
+	}{
+		{name: "owner case", a: SearchFilters{Owner: "01ABC"}, b: SearchFilters{Owner: "01abc"}, same: true},
+		{name: "different owner", a: SearchFilters{Owner: "forsen"}, b: SearchFilters{Owner: "xqc"}},
+		{name: "zero width unset and true", a: SearchFilters{}, b: SearchFilters{ZeroWidth: &yes}},
+		{name: "tag match", a: SearchFilters{Tags: []string{"cat"}, TagMatch: TagMatchAny}, b: SearchFilters{Tags: []string{"cat"}, TagMatch: TagMatchAll}},
+		{name: "case sensitivity", a: SearchFilters{}, b: SearchFilters{CaseSensitive: true}},
+		{name: "exact match", a: SearchFilters{}, b: SearchFilters{ExactMatch: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.a.Key() == tt.b.Key(); same != tt.same {
+				t.Errorf("Key() equal = %t, want %t (%q, %q)", same, tt.same, tt.a.Key(), tt.b.Key())
+			}
+		})
+	}
+}
+
+func TestSearchFiltersApply(t *testing.T) {
+	emote := func(name, ownerID, ownerName string) Emote {
+		e := Emote{ID: name, DefaultName: name}
+		e.Owner.ID = ownerID
+		e.Owner.MainConnection.PlatformDisplayName = ownerName
+		return e
+	}
+	items := []Emote{
+		emote("catJAM", "01AAA", "Forsen"),
+		emote("CATJAM", "01BBB", "xQc"),
+		emote("dogJAM", "01AAA", "Forsen"),
+	}
+
+	tests := []struct {
+		name    string
+		filters SearchFilters
+		query   string
+		want    []string
+	}{
+		{name: "no filters", query: "cat", want: []string{"catJAM", "CATJAM", "dogJAM"}},
+		{name: "owner ID", filters: SearchFilters{Owner: "01aaa"}, want: []string{"catJAM", "dogJAM"}},
+		{name: "owner display name", filters: SearchFilters{Owner: "XQC"}, want: []string{"CATJAM"}},
+		{name: "case sensitive substring", filters: SearchFilters{CaseSensitive: true}, query: "cat", want: []string{"catJAM"}},
+		{name: "case sensitive exact", filters: SearchFilters{CaseSensitive: true, ExactMatch: true}, query: "CATJAM", want: []string{"CATJAM"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filters.apply(append([]Emote(nil), items...), tt.query)
+			var names []string
+			for _, e := range got {
+				names = append(names, e.DefaultName)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("apply = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// services/seventv/pack.go
+package seventv
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"gokeki/models"
+	"gokeki/services/storage"
+)
+
+// WritePack writes a ZIP with the mirrored image of every emote of manifest,
+// streamed from storage, followed by manifest.json. Images missing from
+// storage are left out and added to the manifest failures; a storage error in
+// the middle of an image aborts the archive. Emote file names are rewritten to
+// their path inside the archive.
+func WritePack(w io.Writer, manifest models.PackManifest) error {
+	zw := zip.NewWriter(w)
+
+	used := map[string]bool{}
+	var packed []models.EmoteResponse
+	for _, e := range manifest.Emotes {
+		ext := path.Ext(e.FileName)
+		blobName := storage.ContentBlobName(e.ContentHash, ext)
+		// Opened before the entry is created, so a missing image is reported
+		// instead of leaving an empty file in the archive
+		blob, found, err := storage.OpenBlob(blobName)
+		if err != nil || !found {
+			reason := "image is missing from storage"
+			if err != nil {
+				reason = fmt.Sprintf("reading image from storage failed: %v", err)
+			}
+			manifest.Failures = append(manifest.Failures, models.EmoteFailure{EmoteID: e.EmoteID, EmoteName: e.EmoteName, Reason: reason})
+			continue
+		}
+
+		name := SafeFileName(e.EmoteName) + ext
+		if used[name] {
+			name = SafeFileName(e.EmoteName) + "_" + e.EmoteID + ext
+		}
+		used[name] = true
+
+		// Images are already compressed, so they are stored as is
+		entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: manifest.GeneratedAt})
+		if err != nil {
+			blob.Close()
+			return err
+		}
+		_, err = io.Copy(entry, blob)
+		blob.Close()
+		if err != nil {
+			return fmt.Errorf("streaming %s: %v", blobName, err)
+		}
+
+		e.FileName = name
+		packed = append(packed, e)
+	}
+	manifest.Emotes = packed
+
+	entry, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(entry)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,36 @@
+// services/seventv/phash.go
+package seventv
+
+import (
+	"fmt"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// perceptualHash returns the 64-bit difference hash (dHash) of img as 16 hex
+// digits. The image is reduced to 9x8 grayscale and each bit tells whether a
+// pixel is brighter than its right neighbour, so re-encoded or rescaled copies
+// of an image get hashes only a few bits apart. Transparent pixels count as
+// black.
+func perceptualHash(img image.Image) string {
+	small := image.NewNRGBA(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if luminance(small, x, y) > luminance(small, x+1, y) {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// luminance returns the brightness of a pixel weighted by its opacity.
+func luminance(img *image.NRGBA, x, y int) int {
+	c := img.NRGBAAt(x, y)
+	return (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) * int(c.A) / 255
+}
@@ -0,0 +1,99 @@
+// services/seventv/phash_test.go
+package seventv
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+	"strconv"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+// gradient returns a w x h image whose brightness changes from left to right.
+func gradient(w, h int, increasing bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 255 / (w - 1))
+			if !increasing {
+				v = 255 - v
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+// checker returns a w x h image of 8x8 black and white squares.
+func checker(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x/8+y/8)%2 == 0 {
+				img.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+			} else {
+				img.SetNRGBA(x, y, color.NRGBA{A: 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	uniform := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(uniform, uniform.Bounds(), image.NewUniform(color.NRGBA{R: 200, A: 255}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+	}{
+		{name: "brighter to the right", img: gradient(64, 64, true), want: "0000000000000000"},
+		{name: "darker to the right", img: gradient(64, 64, false), want: "ffffffffffffffff"},
+		{name: "uniform", img: uniform, want: "0000000000000000"},
+		{name: "transparent", img: image.NewNRGBA(image.Rect(0, 0, 16, 16)), want: "0000000000000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := perceptualHash(tt.img); got != tt.want {
+				t.Errorf("perceptualHash = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPerceptualHashDistance(t *testing.T) {
+	distance := func(a, b image.Image) int {
+		ha, _ := strconv.ParseUint(perceptualHash(a), 16, 64)
+		hb, _ := strconv.ParseUint(perceptualHash(b), 16, 64)
+		return bits.OnesCount64(ha ^ hb)
+	}
+	scaled := func(src image.Image, size int) image.Image {
+		dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+		return dst
+	}
+
+	tests := []struct {
+		name    string
+		a, b    image.Image
+		maxDist int
+		minDist int
+	}{
+		{name: "downscaled copy", a: checker(128, 128), b: scaled(checker(128, 128), 32), maxDist: 4},
+		{name: "upscaled copy", a: checker(64, 64), b: scaled(checker(64, 64), 112), maxDist: 4},
+		{name: "mirrored gradient", a: gradient(64, 64, true), b: gradient(64, 64, false), minDist: 64, maxDist: 64},
+		{name: "different images", a: checker(64, 64), b: gradient(64, 64, false), minDist: 16, maxDist: 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := distance(tt.a, tt.b); d < tt.minDist || d > tt.maxDist {
+				t.Errorf("distance = %d, want %d-%d", d, tt.minDist, tt.maxDist)
+			}
+		})
+	}
+}
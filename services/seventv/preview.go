@@ -0,0 +1,167 @@
+// services/seventv/preview.go
+package seventv
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"log"
+
+	"gokeki/config"
+	"gokeki/services/storage"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// maxDecodePixels guards against decompression bombs: images whose declared
+// size is larger are not decoded.
+const maxDecodePixels = 4096 * 4096
+
+// derivedImages holds the blob names of the static renditions generated for a
+// mirrored image and its perceptual hash. Empty values mean they aren't
+// available.
+type derivedImages struct {
+	Preview   string
+	Thumbnail string
+	PHash     string
+}
+
+// derivedNames returns the blob names of the renditions of an image. Static
+// images are their own preview.
+func derivedNames(hash string, info imageInfo, thumbSize int) derivedImages {
+	d := derivedImages{}
+	if info.Animated {
+		d.Preview = storage.DerivedBlobName(hash, "preview", ".png")
+	}
+	if thumbSize > 0 {
+		d.Thumbnail = storage.DerivedBlobName(hash, fmt.Sprintf("thumb%d", thumbSize), ".png")
+	}
+	return d
+}
+
+// generateDerived stores the first frame preview and the thumbnail of a
+// downloaded image and computes the perceptual hash of its first frame,
+// skipping what is already known. Images that can't be decoded in pure Go
+// (AVIF, animated WebP) get neither. Errors are logged and only cost the
+// renditions, never the mirrored image.
+func generateDerived(img *spooledImage, info imageInfo) derivedImages {
+	cfg := config.LoadConfig()
+	if !decodable(info) {
+		return derivedImages{}
+	}
+	d := derivedImages{}
+	if cfg.GeneratePreviews {
+		d = derivedNames(img.Hash, info, cfg.ThumbnailSize)
+	}
+
+	phash, hashKnown := storage.KnownPHash(img.Hash)
+	d.PHash = phash
+	missing := !hashKnown
+	for _, name := range []string{d.Preview, d.Thumbnail} {
+		if name == "" {
+			continue
+		}
+		if ok, err := storage.HasBlob(name); err != nil || !ok {
+			missing = true
+		}
+	}
+	if !missing {
+		return d
+	}
+
+	frame, err := decodeFirstFrame(io.NewSectionReader(img, 0, img.Size), info)
+	if err != nil {
+		log.Printf("⚠️  No preview for %s: %v", img.Hash, err)
+		return derivedImages{}
+	}
+
+	if !hashKnown {
+		d.PHash = perceptualHash(frame)
+		storage.RememberPHash(img.Hash, d.PHash)
+	}
+
+	if d.Preview != "" {
+		if err := storePNG(frame, d.Preview); err != nil {
+			log.Printf("Error storing preview %s: %v", d.Preview, err)
+			d.Preview = ""
+		}
+	}
+	if d.Thumbnail != "" {
+		if err := storePNG(thumbnail(frame, cfg.ThumbnailSize), d.Thumbnail); err != nil {
+			log.Printf("Error storing thumbnail %s: %v", d.Thumbnail, err)
+			d.Thumbnail = ""
+		}
+	}
+	return d
+}
+
+// decodable reports whether the first frame of an image can be decoded: GIF,
+// PNG and static WebP within maxDecodePixels.
+func decodable(info imageInfo) bool {
+	if info.Width*info.Height > maxDecodePixels {
+		return false
+	}
+	switch info.Mime {
+	case "image/gif", "image/png":
+		return true
+	case "image/webp":
+		return !info.Animated
+	}
+	return false
+}
+
+// decodeFirstFrame decodes the first frame of a decodable image.
+func decodeFirstFrame(r io.ReadSeeker, info imageInfo) (image.Image, error) {
+	switch info.Mime {
+	case "image/gif":
+		return decodeGIFFirstFrame(r)
+	case "image/png":
+		return png.Decode(r)
+	case "image/webp":
+		return webp.Decode(r)
+	}
+	return nil, fmt.Errorf("decoding %s is not supported", info.Mime)
+}
+
+// decodeGIFFirstFrame decodes the first frame of a GIF placed on the full
+// canvas, since GIF frames may cover only part of it.
+func decodeGIFFirstFrame(r io.ReadSeeker) (image.Image, error) {
+	cfg, err := gif.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	frame, err := gif.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	if canvas.Bounds().Empty() {
+		return frame, nil
+	}
+	draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	return canvas, nil
+}
+
+// thumbnail scales src to fit a size x size square, keeping its aspect ratio
+// and centering it on a transparent background.
+func thumbnail(src image.Image, size int) image.Image {
+	return resizeImage(src, size, size, FitContain)
+}
+
+// storePNG encodes img as PNG and uploads it unless it already exists.
+func storePNG(img image.Image, blobName string) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	_, err := storage.UploadToAzureBlob(buf.Bytes(), blobName, "image/png", nil)
+	return err
+}
@@ -0,0 +1,66 @@
+// services/seventv/ranking.go
+package seventv
+
+import "gokeki/models"
+
+// Ranking mirrors the 7TV ranking enum accepted by the emote "ranking" field.
+type Ranking string
+
+const (
+	RankingDaily   Ranking = "TRENDING_DAILY"
+	RankingWeekly  Ranking = "TRENDING_WEEKLY"
+	RankingMonthly Ranking = "TRENDING_MONTHLY"
+	RankingAllTime Ranking = "TOP_ALL_TIME"
+)
+
+// RankingForPeriod returns the ranking matching a trending period.
+func RankingForPeriod(period models.TrendingPeriod) Ranking {
+	switch period {
+	case models.Daily:
+		return RankingDaily
+	case models.Monthly:
+		return RankingMonthly
+	case models.AllTime:
+		return RankingAllTime
+	default:
+		return RankingWeekly
+	}
+}
+
+// RankingForSort returns the ranking matching a search sort mode. Sorts that
+// are not popularity based fall back to the weekly ranking.
+func RankingForSort(by SortBy) Ranking {
+	switch by {
+	case SortTrendingDaily:
+		return RankingDaily
+	case SortTrendingMonthly:
+		return RankingMonthly
+	case SortTopAllTime:
+		return RankingAllTime
+	default:
+		return RankingWeekly
+	}
+}
+
+// Period returns the trending period the ranking corresponds to.
+func (r Ranking) Period() models.TrendingPeriod {
+	switch r {
+	case RankingDaily:
+		return models.Daily
+	case RankingMonthly:
+		return models.Monthly
+	case RankingAllTime:
+		return models.AllTime
+	default:
+		return models.Weekly
+	}
+}
+
+// assignRanks records the position of each emote in the 7TV result order and
+// the ranking period its ranking score refers to.
+func assignRanks(emotes []Emote, ranking Ranking) {
+	for i := range emotes {
+		emotes[i].Rank = i + 1
+		emotes[i].RankingPeriod = ranking.Period()
+	}
+}
@@ -0,0 +1,435 @@
+// services/seventv/resize.go
+package seventv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gokeki/services/storage"
+
+	"golang.org/x/image/draw"
+)
+
+// ErrNotResizable is returned when an emote has no image the pure-Go pipeline
+// can decode.
+var ErrNotResizable = errors.New("emote has no decodable image")
+
+// maxResizeSize is the largest width or height that can be requested.
+const maxResizeSize = 1024
+
+// ResizeFit is how an image is fitted into the requested box.
+type ResizeFit string
+
+const (
+	FitContain ResizeFit = "contain" // Scale to fit, pad with transparency
+	FitCover   ResizeFit = "cover"   // Scale to fill, crop the overflow
+	FitFill    ResizeFit = "fill"    // Stretch to the exact box
+)
+
+// ResizeOptions describes a resized rendition of an emote. A zero Width or
+// Height is computed from the other one keeping the aspect ratio. Colors and
+// MaxFrames shrink the file for platforms with size limits; zero keeps full
+// color and every frame.
+type ResizeOptions struct {
+	Width     int
+	Height    int
+	Fit       ResizeFit
+	Format    string // "png" (first frame) or "gif" (keeps GIF animation)
+	Colors    int    // Palette size, 2-256
+	MaxFrames int    // GIF only; frames are merged evenly, keeping the duration
+}
+
+// ParseResizeOptions validates client supplied resize parameters.
+func ParseResizeOptions(width, height, fit, format string) (ResizeOptions, error) {
+	opts := ResizeOptions{Fit: FitContain, Format: "png"}
+
+	for _, p := range []struct {
+		name  string
+		value string
+		dst   *int
+	}{{"width", width, &opts.Width}, {"height", height, &opts.Height}} {
+		if p.value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p.value)
+		if err != nil || n < 1 || n > maxResizeSize {
+			return opts, fmt.Errorf("invalid %s %q. Use 1-%d", p.name, p.value, maxResizeSize)
+		}
+		*p.dst = n
+	}
+	if opts.Width == 0 && opts.Height == 0 {
+		return opts, errors.New("width or height is required")
+	}
+
+	switch ResizeFit(strings.ToLower(fit)) {
+	case "":
+	case FitContain, FitCover, FitFill:
+		opts.Fit = ResizeFit(strings.ToLower(fit))
+	default:
+		return opts, fmt.Errorf("invalid fit %q. Use 'contain', 'cover' or 'fill'", fit)
+	}
+
+	switch strings.ToLower(format) {
+	case "", "png":
+	case "gif":
+		opts.Format = "gif"
+	default:
+		return opts, fmt.Errorf("invalid format %q. Use 'png' or 'gif'", format)
+	}
+	return opts, nil
+}
+
+// Key identifies the rendition, e.g. "resize_128x0_contain" or
+// "resize_112x112_contain_c64_f60".
+func (o ResizeOptions) Key() string {
+	key := fmt.Sprintf("resize_%dx%d_%s", o.Width, o.Height, o.Fit)
+	if o.Colors > 0 {
+		key += fmt.Sprintf("_c%d", o.Colors)
+	}
+	if o.MaxFrames > 0 {
+		key += fmt.Sprintf("_f%d", o.MaxFrames)
+	}
+	return key
+}
+
+// paletteLimit is the number of opaque colors of the output palette, leaving
+// an entry for transparency.
+func (o ResizeOptions) paletteLimit() int {
+	if o.Colors >= 2 && o.Colors <= 256 {
+		return o.Colors - 1
+	}
+	return maxOpaqueColors
+}
+
+// ContentType returns the mime type of the rendition.
+func (o ResizeOptions) ContentType() string {
+	return "image/" + o.Format
+}
+
+// sourcePolicy picks the image the rendition is made from: a GIF to keep the
+// animation, else a static rendition, at the highest scale for quality.
+func (o ResizeOptions) sourcePolicy() ImageSelectionPolicy {
+	if o.Format == "gif" {
+		return ImageSelectionPolicy{Formats: []string{"image/gif", "image/png", "image/webp"}, Variant: VariantAuto}
+	}
+	return ImageSelectionPolicy{Formats: []string{"image/png", "image/webp", "image/gif"}, Variant: VariantStatic}
+}
+
+// ResizeEmote returns the blob holding the rendition of e described by opts,
+// mirroring the source image into folder and creating the rendition when
+// missing. Freshly created renditions are also returned as data.
+func ResizeEmote(e Emote, folder string, opts ResizeOptions) (blobName string, data []byte, err error) {
+	mirrored, err := MirrorEmote(e, folder, opts.sourcePolicy())
+	if err != nil {
+		return "", nil, err
+	}
+	if mirrored == nil || mirrored.ContentHash == "" {
+		return "", nil, ErrNotResizable
+	}
+
+	blobName = storage.DerivedBlobName(mirrored.ContentHash, opts.Key(), "."+opts.Format)
+	if ok, err := storage.HasBlob(blobName); err == nil && ok {
+		return blobName, nil, nil
+	}
+
+	source, _, found, err := storage.DownloadBlob(storage.ContentBlobName(mirrored.ContentHash, path.Ext(mirrored.FileName)))
+	if err != nil {
+		return "", nil, err
+	}
+	if !found {
+		return "", nil, fmt.Errorf("mirrored image of emote %s is missing from storage", e.ID)
+	}
+
+	data, err = resizeBytes(source, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := storage.UploadToAzureBlob(data, blobName, opts.ContentType(), nil); err != nil {
+		return "", nil, err
+	}
+	return blobName, data, nil
+}
+
+// resizeBytes decodes an image, resizes it and encodes it in the requested
+// format. GIF sources keep their animation when the output is a GIF.
+func resizeBytes(data []byte, opts ResizeOptions) ([]byte, error) {
+	info, err := sniffImage(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if !decodable(info) {
+		return nil, ErrNotResizable
+	}
+
+	var buf bytes.Buffer
+	if opts.Format == "gif" && info.Mime == "image/gif" {
+		src, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if err := gif.EncodeAll(&buf, resizeGIF(src, opts)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	frame, err := decodeFirstFrame(bytes.NewReader(data), info)
+	if err != nil {
+		return nil, err
+	}
+	w, h := opts.size(frame.Bounds())
+	resized := resizeImage(frame, w, h, opts.Fit)
+
+	switch {
+	case opts.Format == "gif" && opts.Colors > 0:
+		err = gif.Encode(&buf, quantize(resized, framePalette(resized, nil, opts.paletteLimit())), nil)
+	case opts.Format == "gif":
+		err = gif.Encode(&buf, quantize(resized, palette.Plan9), nil)
+	case opts.Colors > 0:
+		// Paletted PNGs are much smaller than true color ones
+		err = png.Encode(&buf, quantize(resized, framePalette(resized, nil, opts.paletteLimit())))
+	default:
+		err = png.Encode(&buf, resized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// size returns the output size for a source of the given bounds.
+func (o ResizeOptions) size(b image.Rectangle) (int, int) {
+	w, h := o.Width, o.Height
+	switch {
+	case w == 0:
+		w = max(1, b.Dx()*h/b.Dy())
+	case h == 0:
+		h = max(1, b.Dy()*w/b.Dx())
+	}
+	return w, h
+}
+
+// resizeImage scales src into a w x h image according to fit.
+func resizeImage(src image.Image, w, h int, fit ResizeFit) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	b := src.Bounds()
+
+	switch fit {
+	case FitFill:
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	case FitCover:
+		// Crop the source to the aspect ratio of the box, centered
+		crop := b
+		if b.Dx()*h > b.Dy()*w {
+			cw := b.Dy() * w / h
+			crop.Min.X += (b.Dx() - cw) / 2
+			crop.Max.X = crop.Min.X + cw
+		} else {
+			ch := b.Dx() * h / w
+			crop.Min.Y += (b.Dy() - ch) / 2
+			crop.Max.Y = crop.Min.Y + ch
+		}
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+	default:
+		sw, sh := w, max(1, b.Dy()*w/b.Dx())
+		if sh > h {
+			sw, sh = max(1, b.Dx()*h/b.Dy()), h
+		}
+		x, y := (w-sw)/2, (h-sh)/2
+		draw.CatmullRom.Scale(dst, image.Rect(x, y, x+sw, y+sh), src, b, draw.Over, nil)
+	}
+	return dst
+}
+
+// resizeGIF resizes every frame of an animated GIF. Frames are composed onto
+// the full canvas first (GIF frames may only cover part of it) so each output
+// frame is complete. The composed canvas can hold colors of earlier frames and
+// of the global palette, so each output frame gets its own palette. With
+// MaxFrames, consecutive source frames are merged into one output frame that
+// lasts as long as all of them.
+func resizeGIF(src *gif.GIF, opts ResizeOptions) *gif.GIF {
+	bounds := image.Rect(0, 0, src.Config.Width, src.Config.Height)
+	if bounds.Empty() && len(src.Image) > 0 {
+		bounds = src.Image[0].Bounds()
+	}
+	w, h := opts.size(bounds)
+
+	// Colors that can be on the canvas: the global palette and every local
+	// palette seen so far
+	seen := newColorSet()
+	if global, ok := src.Config.ColorModel.(color.Palette); ok {
+		seen.add(global)
+	}
+
+	n := len(src.Image)
+	outFrames := n
+	if opts.MaxFrames > 0 && opts.MaxFrames < n {
+		outFrames = opts.MaxFrames
+	}
+
+	canvas := image.NewNRGBA(bounds)
+	out := &gif.GIF{LoopCount: src.LoopCount, Config: image.Config{Width: w, Height: h}}
+	delay := 0
+	for i, frame := range src.Image {
+		disposal := byte(0)
+		if i < len(src.Disposal) {
+			disposal = src.Disposal[i]
+		}
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, bounds.Min, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		seen.add(frame.Palette)
+		if i < len(src.Delay) {
+			delay += src.Delay[i]
+		}
+
+		// Source frame i belongs to output frame i*outFrames/n; the output
+		// frame is emitted with the last source frame of its group
+		if i == n-1 || (i+1)*outFrames/n != i*outFrames/n {
+			resized := resizeImage(canvas, w, h, opts.Fit)
+			out.Image = append(out.Image, quantize(resized, framePalette(resized, seen.palette, opts.paletteLimit())))
+			out.Delay = append(out.Delay, delay)
+			out.Disposal = append(out.Disposal, gif.DisposalBackground)
+			delay = 0
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return out
+}
+
+// colorSet collects the distinct opaque colors of several palettes, in the
+// order they are first seen.
+type colorSet struct {
+	index   map[color.NRGBA]bool
+	palette color.Palette
+}
+
+func newColorSet() *colorSet {
+	return &colorSet{index: map[color.NRGBA]bool{}}
+}
+
+func (s *colorSet) add(pal color.Palette) {
+	for _, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		if n.A == 0 || s.index[n] {
+			continue
+		}
+		s.index[n] = true
+		s.palette = append(s.palette, n)
+	}
+}
+
+// maxOpaqueColors leaves a palette entry for transparency.
+const maxOpaqueColors = 255
+
+// framePalette picks a palette of at most limit colors for a resized frame:
+// its exact colors when they fit, otherwise the source colors when they fit,
+// otherwise its most frequent colors.
+func framePalette(img *image.NRGBA, source color.Palette, limit int) color.Palette {
+	counts := map[color.NRGBA]int{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			if c.A < 128 {
+				continue
+			}
+			c.A = 255
+			counts[c]++
+		}
+	}
+
+	if len(counts) > limit && len(source) > 0 && len(source) <= limit {
+		return source
+	}
+	colors := make([]color.NRGBA, 0, len(counts))
+	for c := range counts {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		if counts[colors[i]] != counts[colors[j]] {
+			return counts[colors[i]] > counts[colors[j]]
+		}
+		return colorLess(colors[i], colors[j])
+	})
+	if len(colors) > limit {
+		colors = colors[:limit]
+	}
+	pal := make(color.Palette, len(colors))
+	for i, c := range colors {
+		pal[i] = c
+	}
+	return pal
+}
+
+// colorLess orders colors so palettes don't depend on map iteration order.
+func colorLess(a, b color.NRGBA) bool {
+	if a.R != b.R {
+		return a.R < b.R
+	}
+	if a.G != b.G {
+		return a.G < b.G
+	}
+	return a.B < b.B
+}
+
+// quantize maps img onto pal, keeping a transparent entry for pixels that are
+// mostly transparent.
+func quantize(img *image.NRGBA, pal color.Palette) *image.Paletted {
+	p := append(color.Palette{}, pal...)
+	transparent := -1
+	for i, c := range p {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			transparent = i
+			break
+		}
+	}
+	if transparent < 0 {
+		if len(p) == 256 {
+			p = p[:255]
+		}
+		p = append(p, color.Transparent)
+		transparent = len(p) - 1
+	}
+
+	dst := image.NewPaletted(img.Bounds(), p)
+	indexes := map[color.NRGBA]uint8{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			if c.A < 128 {
+				dst.SetColorIndex(x, y, uint8(transparent))
+				continue
+			}
+			c.A = 255
+			idx, ok := indexes[c]
+			if !ok {
+				idx = uint8(p.Index(c))
+				indexes[c] = idx
+			}
+			dst.SetColorIndex(x, y, idx)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,205 @@
+// services/seventv/resize_test.go
+package seventv
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+var (
+	red  = color.NRGBA{R: 255, A: 255}
+	blue = color.NRGBA{B: 255, A: 255}
+)
+
+func TestParseResizeOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		width   string
+		height  string
+		fit     string
+		format  string
+		want    ResizeOptions
+		wantErr bool
+	}{
+		{name: "width only", width: "128", want: ResizeOptions{Width: 128, Fit: FitContain, Format: "png"}},
+		{name: "cover gif", width: "64", height: "32", fit: "Cover", format: "GIF", want: ResizeOptions{Width: 64, Height: 32, Fit: FitCover, Format: "gif"}},
+		{name: "no size", wantErr: true},
+		{name: "too large", width: "2048", wantErr: true},
+		{name: "not a number", height: "big", wantErr: true},
+		{name: "invalid fit", width: "32", fit: "stretch", wantErr: true},
+		{name: "invalid format", width: "32", format: "webp", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResizeOptions(tt.width, tt.height, tt.fit, tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseResizeOptions = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, red)
+	img.SetNRGBA(1, 0, color.NRGBA{B: 250, A: 255})
+	img.SetNRGBA(2, 0, color.NRGBA{R: 255, A: 10})
+
+	fullPalette := make(color.Palette, 256)
+	for i := range fullPalette {
+		fullPalette[i] = color.NRGBA{R: 255, G: uint8(i), A: 255}
+	}
+
+	tests := []struct {
+		name    string
+		palette color.Palette
+		wantLen int
+		want    []color.NRGBA // Per pixel
+	}{
+		{name: "adds transparency", palette: color.Palette{red, blue}, wantLen: 3, want: []color.NRGBA{red, blue, {}}},
+		{name: "keeps existing transparency", palette: color.Palette{color.Transparent, red, blue}, wantLen: 3, want: []color.NRGBA{red, blue, {}}},
+		{name: "full palette", palette: fullPalette, wantLen: 256, want: []color.NRGBA{red, {R: 255, A: 255}, {}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quantize(img, tt.palette)
+			if len(got.Palette) != tt.wantLen {
+				t.Errorf("palette has %d colors, want %d", len(got.Palette), tt.wantLen)
+			}
+			for x, want := range tt.want {
+				if c := color.NRGBAModel.Convert(got.At(x, 0)).(color.NRGBA); c != want {
+					t.Errorf("pixel %d = %v, want %v", x, c, want)
+				}
+			}
+		})
+	}
+}
+
+// twoFrameGIF has a red first frame covering the canvas and a blue second
+// frame, with its own palette, covering only the right half.
+func twoFrameGIF() *gif.GIF {
+	first := image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{red})
+	second := image.NewPaletted(image.Rect(4, 0, 8, 8), color.Palette{blue})
+	return &gif.GIF{
+		Image:    []*image.Paletted{first, second},
+		Delay:    []int{10, 20},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+		Config:   image.Config{ColorModel: color.Palette{red}, Width: 8, Height: 8},
+	}
+}
+
+func TestResizeGIF(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       ResizeOptions
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "downscale", opts: ResizeOptions{Width: 4, Fit: FitContain, Format: "gif"}, wantWidth: 4, wantHeight: 4},
+		{name: "upscale", opts: ResizeOptions{Width: 16, Height: 16, Fit: FitFill, Format: "gif"}, wantWidth: 16, wantHeight: 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := resizeGIF(twoFrameGIF(), tt.opts)
+			if out.Config.Width != tt.wantWidth || out.Config.Height != tt.wantHeight {
+				t.Fatalf("size = %dx%d, want %dx%d", out.Config.Width, out.Config.Height, tt.wantWidth, tt.wantHeight)
+			}
+			if len(out.Image) != 2 || out.Delay[0] != 10 || out.Delay[1] != 20 {
+				t.Fatalf("got %d frames with delays %v, want 2 frames with delays [10 20]", len(out.Image), out.Delay)
+			}
+
+			// The second frame is composed over the first, so its left half
+			// keeps the red of the global palette
+			second := out.Image[1]
+			left := color.NRGBAModel.Convert(second.At(0, tt.wantHeight/2)).(color.NRGBA)
+			right := color.NRGBAModel.Convert(second.At(tt.wantWidth-1, tt.wantHeight/2)).(color.NRGBA)
+			if left != red || right != blue {
+				t.Errorf("second frame is %v | %v, want %v | %v", left, right, red, blue)
+			}
+		})
+	}
+}
+
+func TestResizeGIFMaxFrames(t *testing.T) {
+	src := &gif.GIF{Config: image.Config{ColorModel: color.Palette{red}, Width: 4, Height: 4}}
+	for range 6 {
+		src.Image = append(src.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{red}))
+		src.Delay = append(src.Delay, 5)
+	}
+
+	tests := []struct {
+		maxFrames  int
+		wantDelays []int
+	}{
+		{maxFrames: 0, wantDelays: []int{5, 5, 5, 5, 5, 5}},
+		{maxFrames: 3, wantDelays: []int{10, 10, 10}},
+		{maxFrames: 4, wantDelays: []int{10, 5, 10, 5}},
+		{maxFrames: 10, wantDelays: []int{5, 5, 5, 5, 5, 5}},
+	}
+
+	for _, tt := range tests {
+		out := resizeGIF(src, ResizeOptions{Width: 4, Fit: FitContain, Format: "gif", MaxFrames: tt.maxFrames})
+		if fmt.Sprint(out.Delay) != fmt.Sprint(tt.wantDelays) || len(out.Image) != len(tt.wantDelays) {
+			t.Errorf("MaxFrames %d: %d frames with delays %v, want %v", tt.maxFrames, len(out.Image), out.Delay, tt.wantDelays)
+		}
+	}
+}
+
+func TestFramePaletteLimit(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 1))
+	for x := range 10 {
+		img.SetNRGBA(x, 0, color.NRGBA{R: uint8(x * 20), A: 255})
+	}
+	img.SetNRGBA(9, 0, color.NRGBA{}) // Transparent pixels take no entry
+
+	tests := []struct {
+		name    string
+		source  color.Palette
+		limit   int
+		wantLen int
+	}{
+		{name: "exact colors fit", limit: 255, wantLen: 9},
+		{name: "most frequent colors", limit: 4, wantLen: 4},
+		{name: "source palette fits", source: color.Palette{red, blue}, limit: 4, wantLen: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := framePalette(img, tt.source, tt.limit); len(got) != tt.wantLen {
+				t.Errorf("palette has %d colors, want %d", len(got), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestResizeOptionsKey(t *testing.T) {
+	tests := []struct {
+		opts ResizeOptions
+		want string
+	}{
+		{opts: ResizeOptions{Width: 128, Fit: FitContain}, want: "resize_128x0_contain"},
+		{opts: ResizeOptions{Width: 112, Height: 112, Fit: FitContain, Colors: 64, MaxFrames: 60}, want: "resize_112x112_contain_c64_f60"},
+		{opts: ResizeOptions{Width: 28, Height: 28, Fit: FitCover, MaxFrames: 30}, want: "resize_28x28_cover_f30"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.opts.Key(); got != tt.want {
+			t.Errorf("Key() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// services/seventv/selection.go
+package seventv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImageVariant expresses whether the animated or the static rendition of an
+// emote should be preferred when both are available.
+type ImageVariant string
+
+const (
+	VariantAuto     ImageVariant = "auto"     // Animado si existe, si no estático
+	VariantAnimated ImageVariant = "animated" // Preferir animado
+	VariantStatic   ImageVariant = "static"   // Preferir estático
+)
+
+// formatMimes maps the short format names accepted from clients to the mime
+// types reported by 7TV.
+var formatMimes = map[string]string{
+	"webp": "image/webp",
+	"gif":  "image/gif",
+	"avif": "image/avif",
+	"png":  "image/png",
+}
+
+// defaultFormats is the historical preference order: webp > gif > avif > png.
+var defaultFormats = []string{"image/webp", "image/gif", "image/avif", "image/png"}
+
+// ImageSelectionPolicy decides which of the images 7TV offers for an emote is
+// downloaded and mirrored.
+type ImageSelectionPolicy struct {
+	Formats  []string // Mime types in order of preference
+	MaxScale int      // 0 means no limit
+	MaxWidth int      // 0 means no limit
+	Variant  ImageVariant
+}
+
+// DefaultImageSelectionPolicy returns the policy used when the client does
+// not ask for anything specific: animated when available, webp first,
+// highest scale.
+func DefaultImageSelectionPolicy() ImageSelectionPolicy {
+	return ImageSelectionPolicy{
+		Formats: append([]string(nil), defaultFormats...),
+		Variant: VariantAuto,
+	}
+}
+
+// ParseImageSelectionPolicy builds a policy from client supplied values.
+// Formats may be given as short names (webp, gif, avif, png) or mime types.
+// Empty values fall back to the defaults.
+func ParseImageSelectionPolicy(formats []string, maxScale, maxWidth int, variant string) (ImageSelectionPolicy, error) {
+	policy := DefaultImageSelectionPolicy()
+
+	if len(formats) > 0 {
+		policy.Formats = policy.Formats[:0]
+		seen := map[string]bool{}
+		for _, f := range formats {
+			f = strings.ToLower(strings.TrimSpace(f))
+			if f == "" {
+				continue
+			}
+			mime, ok := formatMimes[f]
+			if !ok {
+				mime, ok = formatMimes[strings.TrimPrefix(f, "image/")]
+			}
+			if !ok {
+				return policy, fmt.Errorf("invalid format %q. Use webp, gif, avif or png", f)
+			}
+			if !seen[mime] {
+				seen[mime] = true
+				policy.Formats = append(policy.Formats, mime)
+			}
+		}
+		if len(policy.Formats) == 0 {
+			policy.Formats = append(policy.Formats, defaultFormats...)
+		}
+	}
+
+	if maxScale < 0 || maxScale > 4 {
+		return policy, fmt.Errorf("invalid max_scale %d. Use a value between 1 and 4", maxScale)
+	}
+	if maxWidth < 0 {
+		return policy, fmt.Errorf("invalid max_width %d", maxWidth)
+	}
+	policy.MaxScale = maxScale
+	policy.MaxWidth = maxWidth
+
+	switch ImageVariant(strings.ToLower(variant)) {
+	case "", VariantAuto:
+		policy.Variant = VariantAuto
+	case VariantAnimated:
+		policy.Variant = VariantAnimated
+	case VariantStatic:
+		policy.Variant = VariantStatic
+	default:
+		return policy, fmt.Errorf("invalid variant %q. Use 'auto', 'animated' or 'static'", variant)
+	}
+
+	return policy, nil
+}
+
+// SplitFormats splits a comma separated query parameter into format names.
+func SplitFormats(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// Key returns a compact, stable representation of the policy suitable for
+// cache keys.
+func (p ImageSelectionPolicy) Key() string {
+	short := make([]string, 0, len(p.Formats))
+	for _, mime := range p.Formats {
+		short = append(short, strings.TrimPrefix(mime, "image/"))
+	}
+	variant := p.Variant
+	if variant == "" {
+		variant = VariantAuto
+	}
+	return fmt.Sprintf("%s:s%d:w%d:%s", strings.Join(short, "."), p.MaxScale, p.MaxWidth, variant)
+}
+
+func (p ImageSelectionPolicy) formatRank(mime string) int {
+	for i, m := range p.Formats {
+		if m == mime {
+			return i
+		}
+	}
+	return len(p.Formats)
+}
+
+func (p ImageSelectionPolicy) withinLimits(img Image) bool {
+	if p.MaxScale > 0 && img.Scale > p.MaxScale {
+		return false
+	}
+	if p.MaxWidth > 0 && img.Width > p.MaxWidth {
+		return false
+	}
+	return true
+}
+
+// Select picks the best image according to the policy. Preferences are soft:
+// when nothing matches the requested variant, formats or size limits the
+// closest available image is returned instead.
+func (p ImageSelectionPolicy) Select(images []Image) *Image {
+	if len(images) == 0 {
+		return nil
+	}
+
+	animatedImages := []Image{}
+	staticImages := []Image{}
+	for _, img := range images {
+		if img.FrameCount > 1 {
+			animatedImages = append(animatedImages, img)
+		} else {
+			staticImages = append(staticImages, img)
+		}
+	}
+
+	var candidates []Image
+	switch {
+	case p.Variant == VariantStatic && len(staticImages) > 0:
+		candidates = staticImages
+	case p.Variant != VariantStatic && len(animatedImages) > 0:
+		candidates = animatedImages
+	case len(staticImages) > 0:
+		candidates = staticImages
+	default:
+		candidates = animatedImages
+	}
+
+	// Images within the size limits first, then by format preference, then
+	// the highest scale that fits (or the smallest one if none fits).
+	sort.SliceStable(candidates, func(i, j int) bool {
+		li, lj := p.withinLimits(candidates[i]), p.withinLimits(candidates[j])
+		if li != lj {
+			return li
+		}
+		ri, rj := p.formatRank(candidates[i].Mime), p.formatRank(candidates[j].Mime)
+		if ri != rj {
+			return ri < rj
+		}
+		if li {
+			return candidates[i].Scale > candidates[j].Scale
+		}
+		return candidates[i].Scale < candidates[j].Scale
+	})
+
+	return &candidates[0]
+}
+
+// BlobSuffix returns the part of the blob name that identifies which image of
+// the emote was mirrored, e.g. "anim_4x".
+func BlobSuffix(img *Image) string {
+	variant := "static"
+	if img.FrameCount > 1 {
+		variant = "anim"
+	}
+	return variant + "_" + strconv.Itoa(img.Scale) + "x"
+}
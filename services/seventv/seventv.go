@@ -2,14 +2,14 @@
 package seventv
 
 import (
-    "bytes"
-    "context"
-    "encoding/json"
-    "io"
-    "log"
-    "net/http"
-    "sort"
-
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"gokeki/config"
 	"gokeki/models"
 	"gokeki/services/storage"
 
@@ -17,9 +17,12 @@ import (
 )
 
 type Owner struct {
+	ID             string `json:"id"`
 	MainConnection struct {
 		PlatformDisplayName string `json:"platformDisplayName"`
 	} `json:"mainConnection"`
+	Style            UserStyle `json:"style"`
+	HighestRoleColor *Color    `json:"highestRoleColor"`
 }
 
 type Image struct {
@@ -28,6 +31,7 @@ type Image struct {
 	Size       int    `json:"size"`
 	Scale      int    `json:"scale"`
 	Width      int    `json:"width"`
+	Height     int    `json:"height"`
 	FrameCount int    `json:"frameCount"`
 }
 
@@ -46,6 +50,15 @@ type Emote struct {
 	Images      []Image      `json:"images"`
 	Ranking     int          `json:"ranking"`
 	InEmoteSets []InEmoteSet `json:"inEmoteSets"`
+
+	Deleted       bool       `json:"deleted"`
+	Flags         EmoteFlags `json:"flags"`
+	ImagesPending bool       `json:"imagesPending"`
+
+	// Position in the result order and the period of Ranking, set by the
+	// fetch functions.
+	Rank          int                   `json:"-"`
+	RankingPeriod models.TrendingPeriod `json:"-"`
 }
 
 type searchResponse struct {
@@ -60,15 +73,29 @@ type searchResponse struct {
 	} `json:"data"`
 }
 
-func Fetch7TVEmotesAPI(query string, limit int, animatedOnly bool) []Emote {
-    url := "https://api.7tv.app/v4/gql"
-    gql := `
+func Fetch7TVEmotesAPI(query string, limit int, animatedOnly bool, searchFilters SearchFilters, searchSort SearchSort) []Emote {
+	// animated_only=false has always meant static emotes for searches
+	animationFilter := StaticOnly
+	if animatedOnly {
+		animationFilter = AnimatedOnly
+	}
+	return Fetch7TVEmotesAdvanced(query, limit, animationFilter, searchFilters, searchSort)
+}
+
+// Fetch7TVEmotesAdvanced searches 7TV with any animation filter, including
+// AllEmotes.
+func Fetch7TVEmotesAdvanced(query string, limit int, animationFilter AnimationFilter, searchFilters SearchFilters, searchSort SearchSort) []Emote {
+	url := "https://api.7tv.app/v4/gql"
+	// The tag match mode, sort order and ranking are enum literals validated
+	// by ParseSearchFilters and ParseSearchSort.
+	ranking := RankingForSort(searchSort.By)
+	gql := fmt.Sprintf(`
     query EmoteSearch($query: String, $tags: [String!]!, $sortBy: SortBy!, $filters: Filters, $page: Int, $perPage: Int!, $isDefaultSetSet: Boolean!, $defaultSetId: Id!) {
       emotes {
         search(
           query: $query
-          tags: { tags: $tags, match: ANY }
-          sort: { sortBy: $sortBy, order: DESCENDING }
+          tags: { tags: $tags, match: %s }
+          sort: { sortBy: $sortBy, order: %s }
           filters: $filters
           page: $page
           perPage: $perPage
@@ -77,10 +104,21 @@ func Fetch7TVEmotesAPI(query string, limit int, animatedOnly bool) []Emote {
             id
             defaultName
             owner {
+              id
               mainConnection {
                 platformDisplayName
               }
+              highestRoleColor {
+                hex
+              }
+            }
+            deleted
+            flags {
+              defaultZeroWidth
+              private
+              publicListed
             }
+            imagesPending
             images {
               url
               mime
@@ -89,7 +127,7 @@ func Fetch7TVEmotesAPI(query string, limit int, animatedOnly bool) []Emote {
               width
               frameCount
             }
-            ranking(ranking: TRENDING_WEEKLY)
+            ranking(ranking: %s)
             inEmoteSets(emoteSetIds: [$defaultSetId]) @include(if: $isDefaultSetSet) {
               emoteSetId
               emote {
@@ -103,27 +141,28 @@ func Fetch7TVEmotesAPI(query string, limit int, animatedOnly bool) []Emote {
         }
       }
     }
-    `
-    // Build filters: align with client boolean semantics.
-    // animated_only=true  => animated: true (solo animados)
-    // animated_only=false => animated: false (solo estáticos)
-    filters := map[string]interface{}{"animated": animatedOnly}
-
-    variables := map[string]interface{}{
-        "defaultSetId":    "",
-        "filters":         filters,
-        "isDefaultSetSet": false,
-        "page":            1,
-        "perPage":         limit,
-        "query":           query,
-        "sortBy":          "TOP_ALL_TIME",
-        "tags":            []string{},
-    }
-    payload := map[string]interface{}{
-        "operationName": "EmoteSearch",
-        "query":         gql,
-        "variables":     variables,
-    }
+    `, searchFilters.TagMatch, searchSort.Order, ranking)
+	filters := searchFilters.gqlFilters(animationFilter)
+	tags := searchFilters.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	variables := map[string]interface{}{
+		"defaultSetId":    "",
+		"filters":         filters,
+		"isDefaultSetSet": false,
+		"page":            1,
+		"perPage":         limit,
+		"query":           query,
+		"sortBy":          searchSort.By,
+		"tags":            tags,
+	}
+	payload := map[string]interface{}{
+		"operationName": "EmoteSearch",
+		"query":         gql,
+		"variables":     variables,
+	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -157,7 +196,9 @@ func Fetch7TVEmotesAPI(query string, limit int, animatedOnly bool) []Emote {
 		return nil
 	}
 
-	return sr.Data.Emotes.Search.Items
+	items := sr.Data.Emotes.Search.Items
+	assignRanks(items, ranking)
+	return searchFilters.apply(items, query)
 }
 
 // AnimationFilter represents the type of emotes to fetch based on animation
@@ -169,7 +210,21 @@ const (
 	StaticOnly                          // Solo emotes estáticos
 )
 
-func Fetch7TVTrendingEmotes(period string, limit int, animatedOnly bool) []Emote {
+// ParseAnimationFilter parses the emote_type parameter: all, animated or
+// static.
+func ParseAnimationFilter(emoteType string) (AnimationFilter, error) {
+	switch emoteType {
+	case "animated":
+		return AnimatedOnly, nil
+	case "static":
+		return StaticOnly, nil
+	case "all":
+		return AllEmotes, nil
+	}
+	return AllEmotes, fmt.Errorf("invalid emote_type %q. Use 'all', 'animated', or 'static'", emoteType)
+}
+
+func Fetch7TVTrendingEmotes(period models.TrendingPeriod, limit int, animatedOnly bool) []Emote {
 	// Convert boolean to AnimationFilter for backward compatibility
 	var animationFilter AnimationFilter
 	if animatedOnly {
@@ -181,9 +236,10 @@ func Fetch7TVTrendingEmotes(period string, limit int, animatedOnly bool) []Emote
 	// Use the advanced function internally
 	return Fetch7TVTrendingEmotesAdvanced(period, limit, animationFilter)
 } // Fetch7TVTrendingEmotesAdvanced allows more granular control over animation filtering
-func Fetch7TVTrendingEmotesAdvanced(period string, limit int, animationFilter AnimationFilter) []Emote {
+func Fetch7TVTrendingEmotesAdvanced(period models.TrendingPeriod, limit int, animationFilter AnimationFilter) []Emote {
 	url := "https://api.7tv.app/v4/gql"
-	gql := `
+	ranking := RankingForPeriod(period)
+	gql := fmt.Sprintf(`
 	query EmoteSearch($query: String, $tags: [String!]!, $sortBy: SortBy!, $filters: Filters, $page: Int, $perPage: Int!, $isDefaultSetSet: Boolean!, $defaultSetId: Id!) {
 	  emotes {
 	    search(
@@ -198,6 +254,7 @@ func Fetch7TVTrendingEmotesAdvanced(period string, limit int, animationFilter An
 	        id
 	        defaultName
 	        owner {
+	          id
 	          mainConnection {
 	            platformDisplayName
 	            __typename
@@ -300,7 +357,7 @@ func Fetch7TVTrendingEmotesAdvanced(period string, limit int, animationFilter An
 	          frameCount
 	          __typename
 	        }
-	        ranking(ranking: TRENDING_WEEKLY)
+	        ranking(ranking: %s)
 	        inEmoteSets(emoteSetIds: [$defaultSetId]) @include(if: $isDefaultSetSet) {
 	          emoteSetId
 	          emote {
@@ -319,20 +376,9 @@ func Fetch7TVTrendingEmotesAdvanced(period string, limit int, animationFilter An
 	    __typename
 	  }
 	}
-	`
+	`, ranking)
 
-	// Convert period to sortBy format
-	var sortBy string
-	switch period {
-	case "trending_daily":
-		sortBy = "TRENDING_DAILY"
-	case "trending_weekly":
-		sortBy = "TRENDING_WEEKLY"
-	case "trending_monthly":
-		sortBy = "TRENDING_MONTHLY"
-	default:
-		sortBy = "TRENDING_MONTHLY"
-	}
+	sortBy := SortForPeriod(period)
 
 	// Build filters object based on animation filter
 	var filters map[string]interface{}
@@ -397,126 +443,193 @@ func Fetch7TVTrendingEmotesAdvanced(period string, limit int, animationFilter An
 		return nil
 	}
 
-	return sr.Data.Emotes.Search.Items
+	items := sr.Data.Emotes.Search.Items
+	assignRanks(items, ranking)
+	return items
 }
 
-func selectBestImage(images []Image) *Image {
-	if len(images) == 0 {
-		return nil
-	}
-
-	// Prioritize animated 4x.webp, etc.
-	animatedImages := []Image{}
-	staticImages := []Image{}
-	for _, img := range images {
-		if img.FrameCount > 1 {
-			animatedImages = append(animatedImages, img)
-		} else {
-			staticImages = append(staticImages, img)
-		}
-	}
+// name sanitizer removed; filenames now use emote ID to ensure uniqueness
 
-	var candidates []Image
-	if len(animatedImages) > 0 {
-		candidates = animatedImages
-	} else {
-		candidates = staticImages
+func extensionForMime(mime string) string {
+	switch mime {
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	case "image/avif":
+		return ".avif"
 	}
-
-	// Sort by preference: webp > gif > avif > png, higher scale
-	preferredMimes := map[string]int{"image/webp": 4, "image/gif": 3, "image/avif": 2, "image/png": 1}
-	sort.Slice(candidates, func(i, j int) bool {
-		mi := preferredMimes[candidates[i].Mime]
-		mj := preferredMimes[candidates[j].Mime]
-		if mi != mj {
-			return mi > mj
-		}
-		return candidates[i].Scale > candidates[j].Scale
-	})
-
-	return &candidates[0]
+	return ".png"
 }
 
-// name sanitizer removed; filenames now use emote ID to ensure uniqueness
-
-func processEmote(e Emote, folder string) *models.EmoteResponse {
-    bestImage := selectBestImage(e.Images)
-    if bestImage == nil {
-        return nil
-    }
+// mirrorFileName returns the logical file name of a mirrored image. Use stable
+// unique naming to avoid collisions between emotes sharing names and between
+// the variants and scales of the same emote that different selection policies
+// may pick.
+func mirrorFileName(e Emote, img *Image) string {
+	return e.ID + "_" + BlobSuffix(img) + extensionForMime(img.Mime)
+}
 
-	resp, err := http.Get(bestImage.URL)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to download emote %s: %v", e.DefaultName, err)
-		return nil
+// processEmote downloads the image of e selected by policy and mirrors it into
+// folder. It returns nil without error when no image matches the policy; the
+// error describes why a matching image could not be mirrored.
+func processEmote(e Emote, folder string, policy ImageSelectionPolicy) (*models.EmoteResponse, error) {
+	bestImage := policy.Select(e.Images)
+	if bestImage == nil {
+		return nil, nil
 	}
-	defer resp.Body.Close()
 
-	data, err := io.ReadAll(resp.Body)
+	img, err := downloadImage(bestImage.URL, config.LoadConfig().MaxImageSize)
 	if err != nil {
-		return nil
+		return nil, err
 	}
+	defer img.Close()
 
-	extension := ".png"
-	switch bestImage.Mime {
-	case "image/webp":
-		extension = ".webp"
-	case "image/gif":
-		extension = ".gif"
-	case "image/avif":
-		extension = ".avif"
+	// Never mirror bytes that aren't the image 7TV described (e.g. an HTML
+	// error page served with a 200)
+	info, err := sniffImage(img, img.Size)
+	if err != nil {
+		return nil, err
 	}
-
-    // Use stable unique naming to avoid collisions between emotes sharing names
-    // and between static/animated variants of the same emote.
-    variant := "static"
-    if bestImage.FrameCount > 1 {
-        variant = "anim"
-    }
-    fileName := e.ID + "_" + variant + extension
-    blobName := folder + "/" + fileName
-
-	url, err := storage.UploadToAzureBlob(data, blobName, bestImage.Mime)
-	if err != nil || url == "" {
-		log.Printf("Error uploading emote %s: %v", e.DefaultName, err)
-		return nil
+	if err := verifyImage(info, bestImage); err != nil {
+		return nil, err
 	}
+	derived := generateDerived(img, info)
 
-	return &models.EmoteResponse{
-		FileName:  fileName,
-		URL:       url,
+	extension := extensionForMime(bestImage.Mime)
+	fileName := mirrorFileName(e, bestImage)
+
+	// The bytes are stored once under their content address; the folder only
+	// keeps a reference to them.
+	meta := storage.EmoteMetadata{
 		EmoteID:   e.ID,
 		EmoteName: e.DefaultName,
 		Owner:     e.Owner.MainConnection.PlatformDisplayName,
 		Animated:  bestImage.FrameCount > 1,
 		Scale:     bestImage.Scale,
 		Mime:      bestImage.Mime,
+		Folder:    folder,
+		Width:     info.Width,
+		Height:    info.Height,
+		Preview:   derived.Preview,
+		Thumbnail: derived.Thumbnail,
+		PHash:     derived.PHash,
+	}
+	if info.Animation != nil {
+		meta.Frames = info.Animation.FrameCount
+		meta.DurationMs = info.Animation.DurationMs
+		meta.PlaybackDurationMs = info.Animation.PlaybackDurationMs
+		meta.LoopCount = info.Animation.LoopCount
+	}
+	contentBlob, err := storage.StoreContent(img, img.Hash, bestImage.Mime, extension, meta)
+	if err != nil {
+		return nil, fmt.Errorf("storage upload failed: %v", err)
+	}
+	if contentBlob == "" {
+		return nil, fmt.Errorf("storage unavailable")
+	}
+
+	_, err = storage.PutReference(folder, storage.Reference{
+		EmoteID:   e.ID,
+		EmoteName: e.DefaultName,
+		FileName:  fileName,
+		SHA256:    img.Hash,
+		Blob:      contentBlob,
+		Mime:      bestImage.Mime,
+		Size:      img.Size,
+	}, meta)
+	if err != nil {
+		return nil, fmt.Errorf("storing reference failed: %v", err)
+	}
+
+	resp := emoteResponse(e, bestImage, folder, fileName, contentBlob, img.Hash)
+	resp.Width, resp.Height = info.Width, info.Height
+	resp.PHash = derived.PHash
+	resp.Animation = info.Animation
+	if derived.Preview != "" {
+		resp.PreviewURL = storage.PublicURL(folder, derived.Preview)
+	}
+	if derived.Thumbnail != "" {
+		resp.ThumbnailURL = storage.PublicURL(folder, derived.Thumbnail)
+	}
+	return resp, nil
+}
+
+// emoteResponse describes a mirrored image of an emote referenced from folder.
+func emoteResponse(e Emote, img *Image, folder, fileName, contentBlob, hash string) *models.EmoteResponse {
+	return &models.EmoteResponse{
+		FileName:    fileName,
+		URL:         storage.PublicURL(folder, contentBlob),
+		ContentHash: hash,
+		EmoteID:     e.ID,
+		EmoteName:   e.DefaultName,
+		Owner:       e.Owner.MainConnection.PlatformDisplayName,
+		OwnerID:     e.Owner.ID,
+		Animated:    img.FrameCount > 1,
+		Scale:       img.Scale,
+		Mime:        img.Mime,
+		Width:       img.Width,
+		Height:      img.Height,
+
+		Rank:          e.Rank,
+		RankingScore:  e.Ranking,
+		RankingPeriod: string(e.RankingPeriod),
+
+		OwnerStyle: toOwnerStyle(e.Owner),
+		Flags:      toEmoteFlags(e),
 	}
 }
 
-func ProcessEmotesBatch(emotes []Emote, folder string) []models.EmoteResponse {
+// ProcessEmotesBatch mirrors emotes into folder, keeping their order, and
+// reports the emotes that could not be mirrored.
+func ProcessEmotesBatch(emotes []Emote, folder string, policy ImageSelectionPolicy) ([]models.EmoteResponse, []models.EmoteFailure) {
+	return runBatch(emotes, func(e Emote) (*models.EmoteResponse, error) {
+		return processEmote(e, folder, policy)
+	})
+}
+
+// MirrorEmotesBatch is ProcessEmotesBatch for MirrorEmote: images already
+// mirrored are not downloaded again.
+func MirrorEmotesBatch(emotes []Emote, folder string, policy ImageSelectionPolicy) ([]models.EmoteResponse, []models.EmoteFailure) {
+	return runBatch(emotes, func(e Emote) (*models.EmoteResponse, error) {
+		return MirrorEmote(e, folder, policy)
+	})
+}
+
+// runBatch runs mirror for every emote, 10 at a time, keeping the input order.
+func runBatch(emotes []Emote, mirror func(Emote) (*models.EmoteResponse, error)) ([]models.EmoteResponse, []models.EmoteFailure) {
 	g, _ := errgroup.WithContext(context.Background())
 	g.SetLimit(10)
 
-	processedChan := make(chan *models.EmoteResponse, len(emotes))
+	// Each worker writes to its own slot so the input (ranking) order is kept.
+	processed := make([]*models.EmoteResponse, len(emotes))
+	errs := make([]error, len(emotes))
 
-	for _, e := range emotes {
-		e := e
+	for i, e := range emotes {
+		i, e := i, e
 		g.Go(func() error {
-			res := processEmote(e, folder)
-			if res != nil {
-				processedChan <- res
-			}
+			processed[i], errs[i] = mirror(e)
 			return nil
 		})
 	}
 
 	_ = g.Wait()
-	close(processedChan)
 
 	var result []models.EmoteResponse
-	for res := range processedChan {
-		result = append(result, *res)
+	var failures []models.EmoteFailure
+	for i, res := range processed {
+		if errs[i] != nil {
+			log.Printf("Failed to mirror emote %s: %v", emotes[i].DefaultName, errs[i])
+			failures = append(failures, models.EmoteFailure{
+				EmoteID:   emotes[i].ID,
+				EmoteName: emotes[i].DefaultName,
+				Reason:    errs[i].Error(),
+			})
+			continue
+		}
+		if res != nil {
+			result = append(result, *res)
+		}
 	}
-	return result
+	return result, failures
 }
@@ -0,0 +1,403 @@
+// services/seventv/sniff.go
+package seventv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"gokeki/models"
+)
+
+// imageInfo is what the bytes of an image say about it, regardless of what
+// 7TV declared. Animation is only set for animated GIF and WebP images.
+type imageInfo struct {
+	Mime      string
+	Width     int
+	Height    int
+	Animated  bool
+	Animation *models.Animation
+}
+
+// sniffLen is how much of an image is read to identify it. Everything but
+// the animation frames is found in the first few hundred bytes.
+const sniffLen = 64 * 1024
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// sniffImage identifies a webp, gif, avif or png image by its magic bytes and
+// decodes its dimensions and whether it is animated.
+func sniffImage(r io.ReaderAt, size int64) (imageInfo, error) {
+	head := make([]byte, min(size, sniffLen))
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return imageInfo{}, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, pngSignature):
+		return sniffPNG(head)
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return sniffGIF(io.NewSectionReader(r, 0, size))
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return sniffWebP(r, size, head)
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		return sniffAVIF(head)
+	}
+	return imageInfo{}, fmt.Errorf("unrecognized image data (starts with %q)", head[:min(len(head), 16)])
+}
+
+// verifyImage checks the sniffed image against the mime and frame count 7TV
+// declared for it. The exact count is compared when the frames were counted
+// (GIF and WebP animations), otherwise only animated against static.
+func verifyImage(info imageInfo, declared *Image) error {
+	if info.Mime != declared.Mime {
+		return fmt.Errorf("content is %s but 7TV declared %s", info.Mime, declared.Mime)
+	}
+	if info.Animation != nil && info.Animation.FrameCount != declared.FrameCount {
+		return fmt.Errorf("content has %d frames but 7TV declared %d", info.Animation.FrameCount, declared.FrameCount)
+	}
+	if info.Animated != (declared.FrameCount > 1) {
+		kind := "static"
+		if info.Animated {
+			kind = "animated"
+		}
+		return fmt.Errorf("content is %s but 7TV declared %d frames", kind, declared.FrameCount)
+	}
+	return nil
+}
+
+// sniffPNG reads the IHDR chunk and looks for an APNG acTL chunk before the
+// image data.
+func sniffPNG(head []byte) (imageInfo, error) {
+	info := imageInfo{Mime: "image/png"}
+	if len(head) < 24 || string(head[12:16]) != "IHDR" {
+		return info, errors.New("corrupt PNG header")
+	}
+	info.Width = int(binary.BigEndian.Uint32(head[16:20]))
+	info.Height = int(binary.BigEndian.Uint32(head[20:24]))
+
+	for off := len(pngSignature); off+12 <= len(head); {
+		length := int(binary.BigEndian.Uint32(head[off : off+4]))
+		chunk := string(head[off+4 : off+8])
+		if chunk == "IDAT" {
+			break
+		}
+		if chunk == "acTL" && off+12 <= len(head) {
+			info.Animated = binary.BigEndian.Uint32(head[off+8:off+12]) > 1
+			break
+		}
+		off += 12 + length
+	}
+	return info, nil
+}
+
+// sniffGIF reads the logical screen size and the frame timings by walking the
+// block structure.
+func sniffGIF(r io.Reader) (imageInfo, error) {
+	info := imageInfo{Mime: "image/gif"}
+	anim, width, height, err := gifAnimation(bufio.NewReader(r))
+	if err != nil {
+		return info, err
+	}
+	info.Width, info.Height, info.Animated = width, height, anim.FrameCount > 1
+	if info.Animated {
+		info.Animation = &anim
+	}
+	return info, nil
+}
+
+// gifAnimation counts the frames of a GIF and collects their delays from the
+// graphic control extensions and the loop count from the NETSCAPE2.0
+// application extension. Without that extension a GIF plays once; with it,
+// browsers play it the stored repetitions plus one.
+func gifAnimation(br *bufio.Reader) (anim models.Animation, width, height int, err error) {
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return anim, 0, 0, errors.New("corrupt GIF header")
+	}
+	width = int(binary.LittleEndian.Uint16(header[6:8]))
+	height = int(binary.LittleEndian.Uint16(header[8:10]))
+	if header[10]&0x80 != 0 {
+		if _, err := br.Discard(3 << (header[10]&0x07 + 1)); err != nil {
+			return anim, 0, 0, errors.New("truncated GIF color table")
+		}
+	}
+
+	anim.LoopCount = 1
+	delay := 0 // Of the next frame, from the last graphic control extension
+	for {
+		block, err := br.ReadByte()
+		if err != nil {
+			return anim, 0, 0, errors.New("truncated GIF (no trailer)")
+		}
+		switch block {
+		case 0x2C: // Image descriptor
+			desc := make([]byte, 9)
+			if _, err := io.ReadFull(br, desc); err != nil {
+				return anim, 0, 0, errors.New("truncated GIF frame")
+			}
+			if desc[8]&0x80 != 0 {
+				if _, err := br.Discard(3 << (desc[8]&0x07 + 1)); err != nil {
+					return anim, 0, 0, errors.New("truncated GIF color table")
+				}
+			}
+			if _, err := br.ReadByte(); err != nil { // LZW minimum code size
+				return anim, 0, 0, errors.New("truncated GIF frame")
+			}
+			if err := skipGIFSubBlocks(br); err != nil {
+				return anim, 0, 0, err
+			}
+			anim.FrameCount++
+			anim.Delays = append(anim.Delays, delay)
+			delay = 0
+		case 0x21: // Extension
+			label, err := br.ReadByte()
+			if err != nil {
+				return anim, 0, 0, errors.New("truncated GIF extension")
+			}
+			switch label {
+			case 0xF9: // Graphic control: packed, delay (1/100 s), transparent index
+				data, err := readGIFSubBlocks(br)
+				if err != nil {
+					return anim, 0, 0, err
+				}
+				if len(data) >= 3 {
+					delay = int(binary.LittleEndian.Uint16(data[1:3])) * 10
+				}
+			case 0xFF: // Application: NETSCAPE2.0 carries the loop count
+				data, err := readGIFSubBlocks(br)
+				if err != nil {
+					return anim, 0, 0, err
+				}
+				if len(data) >= 14 && string(data[0:11]) == "NETSCAPE2.0" && data[11] == 1 {
+					if loops := int(binary.LittleEndian.Uint16(data[12:14])); loops == 0 {
+						anim.LoopCount = 0
+					} else {
+						anim.LoopCount = loops + 1
+					}
+				}
+			default:
+				if err := skipGIFSubBlocks(br); err != nil {
+					return anim, 0, 0, err
+				}
+			}
+		case 0x3B: // Trailer
+			setDurations(&anim)
+			return anim, width, height, nil
+		default:
+			return anim, 0, 0, fmt.Errorf("corrupt GIF (unknown block 0x%02x)", block)
+		}
+	}
+}
+
+// readGIFSubBlocks returns the concatenated data of an extension. Only used
+// for the small extensions the sniffer reads.
+func readGIFSubBlocks(br *bufio.Reader) ([]byte, error) {
+	var data []byte
+	for {
+		n, err := br.ReadByte()
+		if err != nil {
+			return nil, errors.New("truncated GIF data")
+		}
+		if n == 0 {
+			return data, nil
+		}
+		block := make([]byte, n)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return nil, errors.New("truncated GIF data")
+		}
+		data = append(data, block...)
+	}
+}
+
+func skipGIFSubBlocks(br *bufio.Reader) error {
+	for {
+		n, err := br.ReadByte()
+		if err != nil {
+			return errors.New("truncated GIF data")
+		}
+		if n == 0 {
+			return nil
+		}
+		if _, err := br.Discard(int(n)); err != nil {
+			return errors.New("truncated GIF data")
+		}
+	}
+}
+
+// sniffWebP reads the size from the first chunk: VP8X (extended, carries the
+// animation flag), VP8L (lossless) or VP8 (lossy). Animated images are walked
+// for their frame timings.
+func sniffWebP(r io.ReaderAt, size int64, head []byte) (imageInfo, error) {
+	info := imageInfo{Mime: "image/webp"}
+	if len(head) < 30 {
+		return info, errors.New("corrupt WebP header")
+	}
+
+	switch string(head[12:16]) {
+	case "VP8X":
+		info.Animated = head[20]&0x02 != 0
+		info.Width = int(uint32(head[24])|uint32(head[25])<<8|uint32(head[26])<<16) + 1
+		info.Height = int(uint32(head[27])|uint32(head[28])<<8|uint32(head[29])<<16) + 1
+	case "VP8L":
+		if head[20] != 0x2f {
+			return info, errors.New("corrupt WebP lossless header")
+		}
+		bits := binary.LittleEndian.Uint32(head[21:25])
+		info.Width = int(bits&0x3fff) + 1
+		info.Height = int(bits>>14&0x3fff) + 1
+	case "VP8 ":
+		if !bytes.Equal(head[23:26], []byte{0x9d, 0x01, 0x2a}) {
+			return info, errors.New("corrupt WebP lossy header")
+		}
+		info.Width = int(binary.LittleEndian.Uint16(head[26:28]) & 0x3fff)
+		info.Height = int(binary.LittleEndian.Uint16(head[28:30]) & 0x3fff)
+	default:
+		return info, fmt.Errorf("corrupt WebP (unknown chunk %q)", head[12:16])
+	}
+
+	// Timings are best effort: an unusual chunk layout only costs them
+	if info.Animated {
+		if anim, err := webpAnimation(r, size); err == nil {
+			info.Animation = &anim
+		}
+	}
+	return info, nil
+}
+
+// webpAnimation walks the RIFF chunks of an animated WebP, reading the loop
+// count from ANIM and the duration of each ANMF frame. Only chunk headers are
+// read, never the frame data.
+func webpAnimation(r io.ReaderAt, size int64) (models.Animation, error) {
+	anim := models.Animation{}
+	header := make([]byte, 24)
+	for off := int64(12); off+8 <= size; {
+		if _, err := r.ReadAt(header[:8], off); err != nil {
+			return anim, errors.New("truncated WebP chunk")
+		}
+		chunkLen := int64(binary.LittleEndian.Uint32(header[4:8]))
+		switch string(header[0:4]) {
+		case "ANIM": // Background color (4), loop count (2)
+			if _, err := r.ReadAt(header[8:14], off+8); err != nil {
+				return anim, errors.New("truncated WebP ANIM chunk")
+			}
+			anim.LoopCount = int(binary.LittleEndian.Uint16(header[12:14]))
+		case "ANMF": // X, Y, width-1, height-1, duration (3 bytes each), flags
+			if _, err := r.ReadAt(header[8:24], off+8); err != nil {
+				return anim, errors.New("truncated WebP ANMF chunk")
+			}
+			delay := int(uint32(header[20]) | uint32(header[21])<<8 | uint32(header[22])<<16)
+			anim.FrameCount++
+			anim.Delays = append(anim.Delays, delay)
+		}
+		off += 8 + chunkLen + chunkLen&1 // Chunks are padded to an even size
+	}
+	if anim.FrameCount == 0 {
+		return anim, errors.New("animated WebP without frames")
+	}
+	setDurations(&anim)
+	return anim, nil
+}
+
+// minFrameDelay is the shortest delay browsers honour: frames with a delay of
+// 10 ms or less are shown for playbackFrameDelay instead.
+const (
+	minFrameDelay      = 10
+	playbackFrameDelay = 100
+)
+
+// setDurations sums the encoded delays and the delays as browsers play them.
+func setDurations(anim *models.Animation) {
+	anim.DurationMs, anim.PlaybackDurationMs = 0, 0
+	for _, delay := range anim.Delays {
+		anim.DurationMs += delay
+		if delay <= minFrameDelay {
+			delay = playbackFrameDelay
+		}
+		anim.PlaybackDurationMs += delay
+	}
+}
+
+// sniffAVIF checks the ftyp brands and reads the size from the image spatial
+// extent (ispe) properties. Grid images carry a property per tile and one for
+// the whole image, so the largest one wins.
+func sniffAVIF(head []byte) (imageInfo, error) {
+	info := imageInfo{Mime: "image/avif"}
+	ftyp, ok := isoBox(head, "ftyp")
+	if !ok || len(ftyp) < 8 {
+		return info, errors.New("corrupt AVIF header")
+	}
+
+	isAVIF := false
+	brands := append([]byte{}, ftyp[0:4]...)
+	brands = append(brands, ftyp[8:]...)
+	for i := 0; i+4 <= len(brands); i += 4 {
+		switch string(brands[i : i+4]) {
+		case "avis":
+			isAVIF, info.Animated = true, true
+		case "avif":
+			isAVIF = true
+		}
+	}
+	if !isAVIF {
+		return info, fmt.Errorf("not an AVIF file (brand %q)", ftyp[0:4])
+	}
+
+	meta, ok := isoBox(head, "meta")
+	if !ok || len(meta) < 4 {
+		return info, errors.New("AVIF without meta box")
+	}
+	iprp, _ := isoBox(meta[4:], "iprp") // meta is a full box
+	ipco, _ := isoBox(iprp, "ipco")
+	forEachISOBox(ipco, func(boxType string, payload []byte) {
+		if boxType != "ispe" || len(payload) < 12 {
+			return
+		}
+		w := int(binary.BigEndian.Uint32(payload[4:8]))
+		h := int(binary.BigEndian.Uint32(payload[8:12]))
+		if w*h > info.Width*info.Height {
+			info.Width, info.Height = w, h
+		}
+	})
+	if info.Width == 0 {
+		return info, errors.New("AVIF without image size")
+	}
+	return info, nil
+}
+
+// isoBox returns the payload of the first ISO BMFF box of the given type.
+func isoBox(data []byte, boxType string) ([]byte, bool) {
+	var found []byte
+	ok := false
+	forEachISOBox(data, func(t string, payload []byte) {
+		if !ok && t == boxType {
+			found, ok = payload, true
+		}
+	})
+	return found, ok
+}
+
+// forEachISOBox calls fn for every complete box in data.
+func forEachISOBox(data []byte, fn func(boxType string, payload []byte)) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		header := uint64(8)
+		switch size {
+		case 0: // Box extends to the end
+			size = uint64(len(data))
+		case 1: // 64-bit size
+			if len(data) < 16 {
+				return
+			}
+			size, header = binary.BigEndian.Uint64(data[8:16]), 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return
+		}
+		fn(string(data[4:8]), data[header:size])
+		data = data[size:]
+	}
+}
@@ -0,0 +1,264 @@
+// services/seventv/sniff_test.go
+package seventv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"gokeki/models"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withACTL inserts an APNG animation control chunk right after IHDR.
+func withACTL(data []byte, frames uint32) []byte {
+	chunk := make([]byte, 20)
+	binary.BigEndian.PutUint32(chunk[0:4], 8)
+	copy(chunk[4:8], "acTL")
+	binary.BigEndian.PutUint32(chunk[8:12], frames)
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+// encodeGIF encodes one frame per delay (in 1/100 s). loopCount follows
+// image/gif: -1 plays once, 0 forever.
+func encodeGIF(t *testing.T, w, h int, delays []int, loopCount int) []byte {
+	t.Helper()
+	pal := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{LoopCount: loopCount}
+	for range delays {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, w, h), pal))
+	}
+	anim.Delay = delays
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// riff wraps chunks in a WebP RIFF container.
+func riff(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	out := []byte("RIFF")
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	return append(out, body...)
+}
+
+func webpChunk(fourCC string, payload []byte) []byte {
+	out := []byte(fourCC)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(payload)))
+	out = append(out, payload...)
+	if len(payload)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func uint24(v int) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16)}
+}
+
+func vp8lChunk(w, h int) []byte {
+	payload := []byte{0x2f}
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(w-1)|uint32(h-1)<<14)
+	return webpChunk("VP8L", append(payload, 0, 0, 0, 0, 0))
+}
+
+func vp8xChunk(w, h int, animated bool) []byte {
+	flags := byte(0)
+	if animated {
+		flags = 0x02
+	}
+	payload := append([]byte{flags, 0, 0, 0}, uint24(w-1)...)
+	return webpChunk("VP8X", append(payload, uint24(h-1)...))
+}
+
+func TestSniffImage(t *testing.T) {
+	pngData := encodePNG(t, 32, 24)
+
+	tests := []struct {
+		name         string
+		data         []byte
+		wantMime     string
+		wantWidth    int
+		wantHeight   int
+		wantAnimated bool
+		wantErr      bool
+	}{
+		{name: "png", data: pngData, wantMime: "image/png", wantWidth: 32, wantHeight: 24},
+		{name: "apng", data: withACTL(pngData, 3), wantMime: "image/png", wantWidth: 32, wantHeight: 24, wantAnimated: true},
+		{name: "apng with one frame", data: withACTL(pngData, 1), wantMime: "image/png", wantWidth: 32, wantHeight: 24},
+		{name: "static gif", data: encodeGIF(t, 28, 28, []int{0}, -1), wantMime: "image/gif", wantWidth: 28, wantHeight: 28},
+		{name: "animated gif", data: encodeGIF(t, 16, 8, []int{5, 5}, 0), wantMime: "image/gif", wantWidth: 16, wantHeight: 8, wantAnimated: true},
+		{name: "lossless webp", data: riff(vp8lChunk(112, 56)), wantMime: "image/webp", wantWidth: 112, wantHeight: 56},
+		{name: "extended webp", data: riff(vp8xChunk(300, 200, false), vp8lChunk(300, 200)), wantMime: "image/webp", wantWidth: 300, wantHeight: 200},
+		{name: "truncated png", data: pngData[:20], wantErr: true},
+		{name: "truncated gif", data: encodeGIF(t, 4, 4, []int{0}, -1)[:20], wantErr: true},
+		{name: "unknown webp chunk", data: riff(webpChunk("ABCD", make([]byte, 20))), wantErr: true},
+		{name: "unrecognized", data: []byte("<html><body>not found</body></html>"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := sniffImage(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Mime != tt.wantMime || info.Width != tt.wantWidth || info.Height != tt.wantHeight || info.Animated != tt.wantAnimated {
+				t.Errorf("sniffImage = %s %dx%d animated=%t, want %s %dx%d animated=%t",
+					info.Mime, info.Width, info.Height, info.Animated, tt.wantMime, tt.wantWidth, tt.wantHeight, tt.wantAnimated)
+			}
+		})
+	}
+}
+
+func TestVerifyImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     imageInfo
+		declared Image
+		wantErr  bool
+	}{
+		{name: "matches", info: imageInfo{Mime: "image/webp", Animated: true}, declared: Image{Mime: "image/webp", FrameCount: 12}},
+		{name: "static matches", info: imageInfo{Mime: "image/png"}, declared: Image{Mime: "image/png", FrameCount: 1}},
+		{name: "wrong mime", info: imageInfo{Mime: "image/gif"}, declared: Image{Mime: "image/webp", FrameCount: 1}, wantErr: true},
+		{name: "static declared animated", info: imageInfo{Mime: "image/gif"}, declared: Image{Mime: "image/gif", FrameCount: 4}, wantErr: true},
+		{name: "frame count matches", info: imageInfo{Mime: "image/gif", Animated: true, Animation: &models.Animation{FrameCount: 4}}, declared: Image{Mime: "image/gif", FrameCount: 4}},
+		{name: "frame count differs", info: imageInfo{Mime: "image/gif", Animated: true, Animation: &models.Animation{FrameCount: 3}}, declared: Image{Mime: "image/gif", FrameCount: 4}, wantErr: true},
+		{name: "uncounted frames", info: imageInfo{Mime: "image/avif", Animated: true}, declared: Image{Mime: "image/avif", FrameCount: 30}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyImage(tt.info, &tt.declared); (err != nil) != tt.wantErr {
+				t.Errorf("verifyImage error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func animChunk(loops int) []byte {
+	return webpChunk("ANIM", []byte{0, 0, 0, 0, byte(loops), byte(loops >> 8)})
+}
+
+func anmfChunk(w, h, duration int) []byte {
+	payload := append(uint24(0), uint24(0)...)
+	payload = append(payload, uint24(w-1)...)
+	payload = append(payload, uint24(h-1)...)
+	payload = append(payload, uint24(duration)...)
+	payload = append(payload, 0)
+	return webpChunk("ANMF", append(payload, vp8lChunk(w, h)...))
+}
+
+func TestSniffAnimation(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		wantFrames   int
+		wantDelays   []int
+		wantDuration int
+		wantPlayback int
+		wantLoops    int
+	}{
+		{
+			name:       "gif looping forever",
+			data:       encodeGIF(t, 8, 8, []int{4, 7}, 0),
+			wantFrames: 2, wantDelays: []int{40, 70}, wantDuration: 110, wantPlayback: 110, wantLoops: 0,
+		},
+		{
+			name:       "gif without loop extension plays once",
+			data:       encodeGIF(t, 8, 8, []int{10, 10, 10}, -1),
+			wantFrames: 3, wantDelays: []int{100, 100, 100}, wantDuration: 300, wantPlayback: 300, wantLoops: 1,
+		},
+		{
+			name:       "gif repeated twice plays three times",
+			data:       encodeGIF(t, 8, 8, []int{5, 5}, 2),
+			wantFrames: 2, wantDelays: []int{50, 50}, wantDuration: 100, wantPlayback: 100, wantLoops: 3,
+		},
+		{
+			name:       "gif zero delays play at the browser minimum",
+			data:       encodeGIF(t, 8, 8, []int{0, 1, 2}, 0),
+			wantFrames: 3, wantDelays: []int{0, 10, 20}, wantDuration: 30, wantPlayback: 220, wantLoops: 0,
+		},
+		{
+			name:       "animated webp",
+			data:       riff(vp8xChunk(8, 8, true), animChunk(3), anmfChunk(8, 8, 40), anmfChunk(8, 8, 70)),
+			wantFrames: 2, wantDelays: []int{40, 70}, wantDuration: 110, wantPlayback: 110, wantLoops: 3,
+		},
+		{
+			name:       "webp with odd sized chunks",
+			data:       riff(vp8xChunk(8, 8, true), webpChunk("ICCP", []byte{1, 2, 3}), animChunk(0), anmfChunk(8, 8, 0)),
+			wantFrames: 1, wantDelays: []int{0}, wantDuration: 0, wantPlayback: 100, wantLoops: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := sniffImage(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			anim := info.Animation
+			if anim == nil {
+				t.Fatal("no animation details")
+			}
+			if anim.FrameCount != tt.wantFrames || fmt.Sprint(anim.Delays) != fmt.Sprint(tt.wantDelays) {
+				t.Errorf("frames = %d %v, want %d %v", anim.FrameCount, anim.Delays, tt.wantFrames, tt.wantDelays)
+			}
+			if anim.DurationMs != tt.wantDuration || anim.PlaybackDurationMs != tt.wantPlayback {
+				t.Errorf("durations = %d/%d ms, want %d/%d ms", anim.DurationMs, anim.PlaybackDurationMs, tt.wantDuration, tt.wantPlayback)
+			}
+			if anim.LoopCount != tt.wantLoops {
+				t.Errorf("loop count = %d, want %d", anim.LoopCount, tt.wantLoops)
+			}
+		})
+	}
+}
+
+func TestSniffAnimationBestEffort(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "animated webp without frames", data: riff(vp8xChunk(8, 8, true), animChunk(0))},
+		{name: "truncated ANMF chunk", data: riff(vp8xChunk(8, 8, true), webpChunk("ANMF", []byte{0, 0, 0}))[:40]},
+		{name: "static gif", data: encodeGIF(t, 8, 8, []int{10}, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := sniffImage(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("timings must not fail sniffing: %v", err)
+			}
+			if info.Animation != nil {
+				t.Errorf("animation = %+v, want none", info.Animation)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// services/seventv/sort.go
+package seventv
+
+import (
+	"fmt"
+	"strings"
+
+	"gokeki/models"
+)
+
+// SortBy mirrors the 7TV SortBy enum used by emote searches.
+type SortBy string
+
+const (
+	SortTopAllTime      SortBy = "TOP_ALL_TIME"
+	SortTrendingDaily   SortBy = "TRENDING_DAILY"
+	SortTrendingWeekly  SortBy = "TRENDING_WEEKLY"
+	SortTrendingMonthly SortBy = "TRENDING_MONTHLY"
+	SortNewest          SortBy = "UPLOAD_DATE"
+	SortAlphabetical    SortBy = "NAME_ALPHABETICAL"
+)
+
+// SortOrder mirrors the 7TV SortOrder enum.
+type SortOrder string
+
+const (
+	SortDescending SortOrder = "DESCENDING"
+	SortAscending  SortOrder = "ASCENDING"
+)
+
+// sortByNames maps the names accepted from clients to 7TV sort modes.
+var sortByNames = map[string]SortBy{
+	"top_all_time":     SortTopAllTime,
+	"trending_daily":   SortTrendingDaily,
+	"trending_weekly":  SortTrendingWeekly,
+	"trending_monthly": SortTrendingMonthly,
+	"newest":           SortNewest,
+	"alphabetical":     SortAlphabetical,
+}
+
+// SearchSort is the sort mode and direction of a search.
+type SearchSort struct {
+	By    SortBy
+	Order SortOrder
+}
+
+// DefaultSearchSort returns the historical search ordering: most popular first.
+func DefaultSearchSort() SearchSort {
+	return SearchSort{By: SortTopAllTime, Order: SortDescending}
+}
+
+// ParseSearchSort validates client supplied sort values. The direction
+// defaults to descending, except for alphabetical sorting where A-Z is the
+// natural default.
+func ParseSearchSort(by, order string) (SearchSort, error) {
+	sort := DefaultSearchSort()
+
+	if by != "" {
+		sortBy, ok := sortByNames[strings.ToLower(by)]
+		if !ok {
+			return sort, fmt.Errorf("invalid sort_by %q. Use one of: top_all_time, trending_daily, trending_weekly, trending_monthly, newest, alphabetical", by)
+		}
+		sort.By = sortBy
+		if sortBy == SortAlphabetical {
+			sort.Order = SortAscending
+		}
+	}
+
+	switch strings.ToLower(order) {
+	case "":
+	case "desc", "descending":
+		sort.Order = SortDescending
+	case "asc", "ascending":
+		sort.Order = SortAscending
+	default:
+		return sort, fmt.Errorf("invalid sort_order %q. Use 'asc' or 'desc'", order)
+	}
+
+	return sort, nil
+}
+
+// Key returns a compact representation of the sort suitable for cache keys.
+func (s SearchSort) Key() string {
+	return string(s.By) + "." + string(s.Order)
+}
+
+// SortForPeriod returns the sort mode used to list trending emotes for a
+// period. The all-time period is served by the top (popularity) sort.
+func SortForPeriod(period models.TrendingPeriod) SortBy {
+	switch period {
+	case models.Daily:
+		return SortTrendingDaily
+	case models.Monthly:
+		return SortTrendingMonthly
+	case models.AllTime:
+		return SortTopAllTime
+	default:
+		return SortTrendingWeekly
+	}
+}
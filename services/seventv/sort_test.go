@@ -0,0 +1,57 @@
+// services/seventv/sort_test.go
+package seventv
+
+import "testing"
+
+func TestParseSearchSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		by      string
+		order   string
+		want    SearchSort
+		wantErr bool
+	}{
+		{name: "defaults", want: SearchSort{By: SortTopAllTime, Order: SortDescending}},
+		{name: "newest", by: "newest", want: SearchSort{By: SortNewest, Order: SortDescending}},
+		{name: "alphabetical defaults to ascending", by: "alphabetical", want: SearchSort{By: SortAlphabetical, Order: SortAscending}},
+		{name: "alphabetical descending", by: "alphabetical", order: "desc", want: SearchSort{By: SortAlphabetical, Order: SortDescending}},
+		{name: "case insensitive", by: "TRENDING_WEEKLY", order: "Ascending", want: SearchSort{By: SortTrendingWeekly, Order: SortAscending}},
+		{name: "invalid sort_by", by: "random", wantErr: true},
+		{name: "invalid sort_order", order: "up", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSearchSort(tt.by, tt.order)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSearchSort(%q, %q) = %+v, want %+v", tt.by, tt.order, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchSortKey(t *testing.T) {
+	tests := []struct {
+		sort SearchSort
+		want string
+	}{
+		{sort: DefaultSearchSort(), want: "TOP_ALL_TIME.DESCENDING"},
+		{sort: SearchSort{By: SortAlphabetical, Order: SortAscending}, want: "NAME_ALPHABETICAL.ASCENDING"},
+		{sort: SearchSort{By: SortNewest, Order: SortAscending}, want: "UPLOAD_DATE.ASCENDING"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.sort.Key(); got != tt.want {
+			t.Errorf("Key() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// services/seventv/sprite.go
+package seventv
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"math"
+	"path"
+	"time"
+
+	"gokeki/models"
+	"gokeki/services/cache"
+	"gokeki/services/storage"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	spritePrefix   = "sprites/"
+	spritePadding  = 1    // Transparent pixels between sprites, against bleeding
+	maxSpriteWidth = 4096 // Widest sheet generated
+)
+
+// spritePolicy selects the static rendition of each emote of a sprite sheet.
+var spritePolicy = ImageSelectionPolicy{Formats: []string{"image/png", "image/webp", "image/gif"}, Variant: VariantStatic}
+
+// SpriteSheet is a stored sprite sheet and its atlas.
+type SpriteSheet struct {
+	Fingerprint string
+	ImageBlob   string
+	AtlasBlob   string
+	Atlas       models.SpriteAtlas
+	Generated   bool
+	Failures    []models.EmoteFailure
+}
+
+// BuildSpriteSheet returns the sprite sheet of emotes scaled to size pixels
+// high, keyed by their aliases (DefaultName). Sheets are identified by a
+// fingerprint of the size, aliases and image contents, so a stored sheet is
+// reused until the emotes change, and then a new one is generated. source
+// names where the emotes come from (e.g. "set:<id>"); the sheet it had before
+// is deleted once no other source uses it.
+func BuildSpriteSheet(emotes []Emote, folder, source string, size int) (*SpriteSheet, error) {
+	mirrored, failures := MirrorEmotesBatch(emotes, folder, spritePolicy)
+	if len(mirrored) == 0 {
+		return nil, errors.New("no emote image could be mirrored")
+	}
+
+	sheet := &SpriteSheet{Fingerprint: spriteFingerprint(mirrored, size), Failures: failures}
+	sheet.ImageBlob = spritePrefix + sheet.Fingerprint + ".png"
+	sheet.AtlasBlob = spritePrefix + sheet.Fingerprint + ".json"
+
+	if data, _, found, err := storage.DownloadBlob(sheet.AtlasBlob); err == nil && found {
+		if err := json.Unmarshal(data, &sheet.Atlas); err == nil {
+			sheet.Failures = append(sheet.Failures, sheet.Atlas.Failures...)
+			sheet.supersede(fmt.Sprintf("%s:%d", source, size))
+			return sheet, nil
+		}
+	}
+
+	if err := sheet.generate(mirrored, size); err != nil {
+		return nil, err
+	}
+	sheet.Generated = true
+	sheet.supersede(fmt.Sprintf("%s:%d", source, size))
+	return sheet, nil
+}
+
+// spriteFingerprint hashes what a sheet is made of.
+func spriteFingerprint(mirrored []models.EmoteResponse, size int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v2:%d\n", size)
+	for _, e := range mirrored {
+		fmt.Fprintf(h, "%s:%s:%s\n", e.EmoteName, e.EmoteID, e.ContentHash)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// generate decodes and scales every image, packs them into rows and stores
+// the sheet and its atlas. The atlas is written last, so a stored atlas always
+// has its sheet.
+func (s *SpriteSheet) generate(mirrored []models.EmoteResponse, size int) error {
+	type sprite struct {
+		emote models.EmoteResponse
+		img   image.Image
+		at    image.Rectangle
+	}
+	var sprites []sprite
+	var decodeFailures []models.EmoteFailure
+	for _, e := range mirrored {
+		data, _, found, err := storage.DownloadBlob(storage.ContentBlobName(e.ContentHash, path.Ext(e.FileName)))
+		if err == nil && !found {
+			err = errors.New("image is missing from storage")
+		}
+		var img image.Image
+		if err == nil {
+			img, err = decodeSprite(data, size)
+		}
+		if err != nil {
+			decodeFailures = append(decodeFailures, models.EmoteFailure{EmoteID: e.EmoteID, EmoteName: e.EmoteName, Reason: err.Error()})
+			continue
+		}
+		sprites = append(sprites, sprite{emote: e, img: img})
+	}
+	if len(sprites) == 0 {
+		return errors.New("no emote image could be decoded")
+	}
+
+	// Rows of equal height: aim for a roughly square sheet
+	area, widest := 0, 0
+	for _, sp := range sprites {
+		w := sp.img.Bounds().Dx() + spritePadding
+		area += w * (size + spritePadding)
+		widest = max(widest, w)
+	}
+	sheetWidth := min(maxSpriteWidth, max(widest, int(math.Ceil(math.Sqrt(float64(area))))))
+
+	atlas := models.SpriteAtlas{Size: size, Frames: map[string]models.SpriteFrame{}, Failures: decodeFailures}
+	x, y := 0, 0
+	for i := range sprites {
+		sp := &sprites[i]
+		w := sp.img.Bounds().Dx()
+		if x > 0 && x+w > sheetWidth {
+			x, y = 0, y+size+spritePadding
+		}
+		sp.at = image.Rect(x, y, x+w, y+size)
+
+		// Aliases are unique within a set; ID lists may repeat names
+		alias := sp.emote.EmoteName
+		if _, taken := atlas.Frames[alias]; taken {
+			alias += "_" + sp.emote.EmoteID
+		}
+		atlas.Frames[alias] = models.SpriteFrame{EmoteID: sp.emote.EmoteID, X: x, Y: y, W: w, H: size}
+		atlas.Width = max(atlas.Width, x+w)
+		x += w + spritePadding
+	}
+	atlas.Height = y + size
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, atlas.Width, atlas.Height))
+	for _, sp := range sprites {
+		draw.Draw(canvas, sp.at, sp.img, sp.img.Bounds().Min, draw.Src)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return err
+	}
+	if _, err := storage.UploadToAzureBlob(buf.Bytes(), s.ImageBlob, "image/png", nil); err != nil {
+		return err
+	}
+
+	atlas.Image = path.Base(s.ImageBlob)
+	atlasJSON, err := json.Marshal(atlas)
+	if err != nil {
+		return err
+	}
+	if _, err := storage.UploadToAzureBlob(atlasJSON, s.AtlasBlob, "application/json", nil); err != nil {
+		return err
+	}
+	s.Atlas = atlas
+	s.Failures = append(s.Failures, decodeFailures...)
+	return nil
+}
+
+// Every source remembers the fingerprint of its last sheet, and every sheet
+// the sources using it, so superseded sheets can be deleted without breaking
+// another source with the same emotes. Sources are hashed since ID lists can
+// be long. Entries expire after spriteSourceTTL of not being requested.
+const spriteSourceTTL = 30 * 24 * time.Hour
+
+func spriteSourceKey(source string) string {
+	return "sprite_source:" + source
+}
+
+func spriteUsersKey(fingerprint string) string {
+	return "sprite_users:" + fingerprint
+}
+
+// supersede records the sheet as the current one of source and deletes the
+// previous sheet of source when no other source uses it. The atlas is deleted
+// first, so a stored atlas always has its sheet.
+func (s *SpriteSheet) supersede(source string) {
+	if cache.RedisClient == nil {
+		return
+	}
+	ctx := context.Background()
+	sum := sha256.Sum256([]byte(source))
+	source = hex.EncodeToString(sum[:])[:32]
+
+	previous, _ := cache.RedisClient.Get(ctx, spriteSourceKey(source)).Result()
+	cache.RedisClient.Set(ctx, spriteSourceKey(source), s.Fingerprint, spriteSourceTTL)
+	cache.RedisClient.SAdd(ctx, spriteUsersKey(s.Fingerprint), source)
+	cache.RedisClient.Expire(ctx, spriteUsersKey(s.Fingerprint), spriteSourceTTL)
+	if previous == "" || previous == s.Fingerprint {
+		return
+	}
+
+	usersKey := spriteUsersKey(previous)
+	cache.RedisClient.SRem(ctx, usersKey, source)
+	if n, err := cache.RedisClient.SCard(ctx, usersKey).Result(); err != nil || n > 0 {
+		return
+	}
+	for _, blobName := range []string{spritePrefix + previous + ".json", spritePrefix + previous + ".png"} {
+		if err := storage.DeleteBlob(blobName); err != nil {
+			log.Printf("❌ Failed to delete superseded sprite sheet %s: %v", blobName, err)
+			return
+		}
+	}
+	cache.RedisClient.Del(ctx, usersKey)
+}
+
+// decodeSprite decodes the first frame of an image scaled to size pixels high.
+func decodeSprite(data []byte, size int) (image.Image, error) {
+	info, err := sniffImage(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if !decodable(info) {
+		return nil, ErrNotResizable
+	}
+	frame, err := decodeFirstFrame(bytes.NewReader(data), info)
+	if err != nil {
+		return nil, err
+	}
+	w, h := ResizeOptions{Height: size}.size(frame.Bounds())
+	return resizeImage(frame, w, h, FitFill), nil
+}
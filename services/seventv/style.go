@@ -0,0 +1,122 @@
+// services/seventv/style.go
+package seventv
+
+import "gokeki/models"
+
+type Color struct {
+	Hex string `json:"hex"`
+}
+
+type PaintStop struct {
+	At    float64 `json:"at"`
+	Color Color   `json:"color"`
+}
+
+// PaintLayerType is the union of the 7TV paint layer types. Only the fields of
+// the concrete type named by Typename are populated.
+type PaintLayerType struct {
+	Typename  string      `json:"__typename"`
+	Color     *Color      `json:"color"`
+	Angle     float64     `json:"angle"`
+	Repeating bool        `json:"repeating"`
+	Stops     []PaintStop `json:"stops"`
+	Shape     string      `json:"shape"`
+	Images    []Image     `json:"images"`
+}
+
+type PaintLayer struct {
+	ID      string         `json:"id"`
+	Ty      PaintLayerType `json:"ty"`
+	Opacity float64        `json:"opacity"`
+}
+
+type PaintShadow struct {
+	Color   Color   `json:"color"`
+	OffsetX float64 `json:"offsetX"`
+	OffsetY float64 `json:"offsetY"`
+	Blur    float64 `json:"blur"`
+}
+
+type Paint struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Data struct {
+		Layers  []PaintLayer  `json:"layers"`
+		Shadows []PaintShadow `json:"shadows"`
+	} `json:"data"`
+}
+
+type UserStyle struct {
+	ActivePaint *Paint `json:"activePaint"`
+}
+
+type EmoteFlags struct {
+	DefaultZeroWidth bool `json:"defaultZeroWidth"`
+	Private          bool `json:"private"`
+	PublicListed     bool `json:"publicListed"`
+}
+
+// paintLayerTypes maps 7TV layer type names to the names used in responses.
+var paintLayerTypes = map[string]string{
+	"PaintLayerTypeSingleColor":    "single_color",
+	"PaintLayerTypeLinearGradient": "linear_gradient",
+	"PaintLayerTypeRadialGradient": "radial_gradient",
+	"PaintLayerTypeImage":          "image",
+}
+
+// toOwnerStyle converts the owner's role color and active paint, returning nil
+// when the owner has neither.
+func toOwnerStyle(o Owner) *models.OwnerStyle {
+	if o.HighestRoleColor == nil && o.Style.ActivePaint == nil {
+		return nil
+	}
+
+	style := &models.OwnerStyle{}
+	if o.HighestRoleColor != nil {
+		style.Color = o.HighestRoleColor.Hex
+	}
+
+	if p := o.Style.ActivePaint; p != nil {
+		paint := &models.Paint{ID: p.ID, Name: p.Name}
+		for _, l := range p.Data.Layers {
+			layer := models.PaintLayer{
+				Type:      paintLayerTypes[l.Ty.Typename],
+				Opacity:   l.Opacity,
+				Angle:     l.Ty.Angle,
+				Repeating: l.Ty.Repeating,
+				Shape:     l.Ty.Shape,
+			}
+			if l.Ty.Color != nil {
+				layer.Color = l.Ty.Color.Hex
+			}
+			for _, stop := range l.Ty.Stops {
+				layer.Stops = append(layer.Stops, models.PaintStop{At: stop.At, Color: stop.Color.Hex})
+			}
+			if img := DefaultImageSelectionPolicy().Select(l.Ty.Images); img != nil {
+				layer.ImageURL = img.URL
+			}
+			paint.Layers = append(paint.Layers, layer)
+		}
+		for _, s := range p.Data.Shadows {
+			paint.Shadows = append(paint.Shadows, models.PaintShadow{
+				Color:   s.Color.Hex,
+				OffsetX: s.OffsetX,
+				OffsetY: s.OffsetY,
+				Blur:    s.Blur,
+			})
+		}
+		style.Paint = paint
+	}
+
+	return style
+}
+
+func toEmoteFlags(e Emote) *models.EmoteFlags {
+	return &models.EmoteFlags{
+		Deleted:       e.Deleted,
+		Private:       e.Flags.Private,
+		PublicListed:  e.Flags.PublicListed,
+		ZeroWidth:     e.Flags.DefaultZeroWidth,
+		ImagesPending: e.ImagesPending,
+	}
+}
@@ -0,0 +1,107 @@
+// services/seventv/visibility.go
+package seventv
+
+import (
+	"fmt"
+
+	"gokeki/config"
+	"gokeki/models"
+)
+
+// VisibilityPolicy decides which emotes are returned and mirrored based on
+// their moderation state. Hidden emotes are dropped before processing so their
+// images are never downloaded.
+type VisibilityPolicy struct {
+	ShowDeleted  bool
+	ShowPrivate  bool
+	ShowUnlisted bool
+	ShowPending  bool
+}
+
+// NewVisibilityPolicy returns the configured default policy.
+func NewVisibilityPolicy(cfg *config.Config) VisibilityPolicy {
+	return VisibilityPolicy{
+		ShowDeleted:  cfg.ShowDeletedEmotes,
+		ShowPrivate:  cfg.ShowPrivateEmotes,
+		ShowUnlisted: cfg.ShowUnlistedEmotes,
+		ShowPending:  cfg.ShowPendingEmotes,
+	}
+}
+
+// ForLookup returns the policy for emotes requested explicitly by ID or by
+// emote set. Unlisted emotes are only left out of discovery (search and
+// trending); whoever asks for one by ID or uses it in a set still gets it.
+func (p VisibilityPolicy) ForLookup() VisibilityPolicy {
+	p.ShowUnlisted = true
+	return p
+}
+
+// WithOverrides returns a copy of the policy with the per-request overrides
+// applied. Nil values keep the current setting.
+func (p VisibilityPolicy) WithOverrides(deleted, private, unlisted, pending *bool) VisibilityPolicy {
+	if deleted != nil {
+		p.ShowDeleted = *deleted
+	}
+	if private != nil {
+		p.ShowPrivate = *private
+	}
+	if unlisted != nil {
+		p.ShowUnlisted = *unlisted
+	}
+	if pending != nil {
+		p.ShowPending = *pending
+	}
+	return p
+}
+
+// Key returns a compact representation of the policy suitable for cache keys.
+func (p VisibilityPolicy) Key() string {
+	flag := func(show bool, c byte) string {
+		if show {
+			return string(c)
+		}
+		return "-"
+	}
+	return fmt.Sprintf("v=%s%s%s%s",
+		flag(p.ShowDeleted, 'd'), flag(p.ShowPrivate, 'p'), flag(p.ShowUnlisted, 'u'), flag(p.ShowPending, 'i'))
+}
+
+// hiddenReason returns why the policy hides the emote, or "" if it is visible.
+func (p VisibilityPolicy) hiddenReason(e Emote) string {
+	switch {
+	case e.Deleted && !p.ShowDeleted:
+		return "deleted"
+	case e.ImagesPending && !p.ShowPending:
+		return "pending"
+	case e.Flags.Private && !p.ShowPrivate:
+		return "private"
+	case !e.Flags.PublicListed && !p.ShowUnlisted:
+		return "unlisted"
+	}
+	return ""
+}
+
+// FilterVisible removes the emotes hidden by the policy. Each removed emote is
+// counted once, under the first matching reason (deleted, pending, private,
+// unlisted).
+func FilterVisible(emotes []Emote, p VisibilityPolicy) ([]Emote, models.FilterStats) {
+	var stats models.FilterStats
+	visible := make([]Emote, 0, len(emotes))
+	for _, e := range emotes {
+		switch p.hiddenReason(e) {
+		case "":
+			visible = append(visible, e)
+			continue
+		case "deleted":
+			stats.Deleted++
+		case "pending":
+			stats.Pending++
+		case "private":
+			stats.Private++
+		case "unlisted":
+			stats.Unlisted++
+		}
+		stats.Total++
+	}
+	return visible, stats
+}
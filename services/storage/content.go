@@ -0,0 +1,222 @@
+// services/storage/content.go
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"gokeki/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+// Image bytes are stored once under their SHA-256 content address. Folders
+// (emote_api, trending_emotes, ...) only hold small JSON references pointing
+// at the content blob, so the same image is never stored twice.
+const objectsPrefix = "objects/"
+
+// Reference metadata keys.
+const (
+	metaSHA256   = "sha256"
+	metaContent  = "content"
+	metaFileName = "filename"
+)
+
+// Reference is the JSON document stored in a folder for a mirrored image.
+type Reference struct {
+	EmoteID   string    `json:"emoteId"`
+	EmoteName string    `json:"emoteName"`
+	FileName  string    `json:"fileName"`
+	SHA256    string    `json:"sha256"`
+	Blob      string    `json:"blob"`
+	Mime      string    `json:"mime"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ContentHash returns the hex encoded SHA-256 of data.
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentBlobName returns the content address of an image, sharded by the
+// first byte of the hash: objects/ab/ab12...ef.webp
+func ContentBlobName(hash, ext string) string {
+	return objectsPrefix + hash[:2] + "/" + hash + ext
+}
+
+// ReferenceBlobName returns the name of the reference for a logical file name
+// inside a folder: <folder>/<file name without extension>.json
+func ReferenceBlobName(folder, fileName string) string {
+	return folder + "/" + strings.TrimSuffix(fileName, path.Ext(fileName)) + ".json"
+}
+
+// IsReference reports whether a listed blob is a reference and returns the
+// content blob and logical file name it points at.
+func IsReference(metadata map[string]*string) (contentBlob, fileName string, ok bool) {
+	contentBlob = MetadataValue(metadata, metaContent)
+	return contentBlob, MetadataValue(metadata, metaFileName), contentBlob != ""
+}
+
+// StoredReference is what a folder reference points at, with the emote
+// metadata it was written with.
+type StoredReference struct {
+	ContentBlob string
+	Hash        string
+	Meta        EmoteMetadata
+}
+
+// GetReference returns the reference of fileName in folder. found is false
+// when the image was never mirrored into the folder.
+func GetReference(folder, fileName string) (ref StoredReference, found bool, err error) {
+	blobName := ReferenceBlobName(folder, fileName)
+	if known, ok := knownReference(blobName); ok {
+		return known, true, nil
+	}
+
+	metadata, exists, err := GetBlobMetadata(blobName)
+	if err != nil || !exists {
+		return ref, false, err
+	}
+	contentBlob, _, ok := IsReference(metadata)
+	ref = StoredReference{ContentBlob: contentBlob, Hash: MetadataValue(metadata, metaSHA256)}
+	if stored, hasEmote := ParseEmoteMetadata(metadata); ok && hasEmote {
+		ref.Meta = stored
+		rememberReference(blobName, ref)
+	}
+	return ref, ok, nil
+}
+
+// knownReference returns the reference remembered for a reference blob.
+func knownReference(blobName string) (StoredReference, bool) {
+	var ref StoredReference
+	value, ok := knownBlob(blobName)
+	if !ok || json.Unmarshal([]byte(value), &ref) != nil || ref.ContentBlob == "" {
+		return ref, false
+	}
+	return ref, true
+}
+
+// rememberReference records a reference whose blob metadata is complete.
+func rememberReference(blobName string, ref StoredReference) {
+	value, err := json.Marshal(ref)
+	if err != nil {
+		return
+	}
+	rememberBlob(blobName, string(value))
+}
+
+// legacyBlobName returns where the image of an emote was stored before
+// content addressing: <folder>/<emote id>_<anim|static>.<ext>
+func legacyBlobName(folder, fileName string, meta EmoteMetadata) string {
+	variant := "static"
+	if meta.Animated {
+		variant = "anim"
+	}
+	return folder + "/" + meta.EmoteID + "_" + variant + path.Ext(fileName)
+}
+
+// StoreContent uploads the body read from r under the content address of
+// hash unless it is already stored, returning the content blob name. It
+// returns an empty blob name when storage is unavailable. The metadata of a
+// content blob describes the emote that first uploaded it.
+func StoreContent(r io.Reader, hash, contentType, ext string, meta EmoteMetadata) (string, error) {
+	if !AzureStorageAvailable() {
+		return "", nil
+	}
+
+	blobName := ContentBlobName(hash, ext)
+	if _, err := UploadStreamToAzureBlob(r, blobName, contentType, meta.toMap(nil)); err != nil {
+		return "", err
+	}
+	return blobName, nil
+}
+
+// PutReference stores the reference for ref.FileName in folder, with meta as
+// blob metadata. Nothing is written when the stored reference already points
+// at the same content with the same emote metadata. updated reports whether
+// an existing reference pointed at different content, i.e. the image changed
+// upstream.
+func PutReference(folder string, ref Reference, meta EmoteMetadata) (updated bool, err error) {
+	if !AzureStorageAvailable() {
+		return false, nil
+	}
+
+	blobName := ReferenceBlobName(folder, ref.FileName)
+	digest := meta.digest()
+	if known, ok := knownReference(blobName); ok && known.Hash == ref.SHA256 && known.Meta.digest() == digest {
+		return false, nil
+	}
+
+	metadata, exists, err := GetBlobMetadata(blobName)
+	if err != nil {
+		return false, err
+	}
+	sameContent := exists && MetadataValue(metadata, metaSHA256) == ref.SHA256
+	stored, hasEmote := ParseEmoteMetadata(metadata)
+	if sameContent && hasEmote && stored.digest() == digest {
+		rememberReference(blobName, StoredReference{ContentBlob: ref.Blob, Hash: ref.SHA256, Meta: stored})
+		return false, nil
+	}
+
+	ref.UpdatedAt = time.Now().UTC()
+	body, err := json.Marshal(ref)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = serviceClient.UploadBuffer(context.Background(), config.LoadConfig().ContainerName, blobName, body, &azblob.UploadBufferOptions{
+		HTTPHeaders: &blob.HTTPHeaders{
+			BlobContentType: to.Ptr("application/json"),
+		},
+		Metadata: meta.toMap(map[string]*string{
+			metaSHA256:   to.Ptr(ref.SHA256),
+			metaContent:  to.Ptr(ref.Blob),
+			metaFileName: to.Ptr(ref.FileName),
+		}),
+	})
+	if err != nil {
+		return false, err
+	}
+	rememberReference(blobName, StoredReference{ContentBlob: ref.Blob, Hash: ref.SHA256, Meta: meta})
+
+	updateIndex(folder+"/", IndexEntry{
+		BlobName:     blobName,
+		FileName:     ref.FileName,
+		ContentBlob:  ref.Blob,
+		EmoteID:      meta.EmoteID,
+		EmoteName:    meta.EmoteName,
+		Owner:        meta.Owner,
+		Animated:     meta.Animated,
+		Scale:        meta.Scale,
+		Mime:         meta.Mime,
+		Width:        meta.Width,
+		Height:       meta.Height,
+		Preview:      meta.Preview,
+		Thumbnail:    meta.Thumbnail,
+		PHash:        meta.PHash,
+		Frames:       meta.Frames,
+		DurationMs:   meta.DurationMs,
+		PlaybackMs:   meta.PlaybackDurationMs,
+		LoopCount:    meta.LoopCount,
+		LastModified: ref.UpdatedAt,
+	})
+
+	if exists && !sameContent {
+		log.Printf("🔄 Image of emote %s changed upstream, reference %s updated", ref.EmoteID, blobName)
+		// Content and derived blobs live under their hash and are never
+		// overwritten; only the folder scoped paths of the emote change
+		purgeCDN(folder, blobName, legacyBlobName(folder, ref.FileName, meta))
+	}
+	return exists && !sameContent, nil
+}
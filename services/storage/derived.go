@@ -0,0 +1,27 @@
+// services/storage/derived.go
+package storage
+
+// Images derived from a mirrored image (previews, thumbnails, resized
+// renditions) are stored next to the content, keyed by its hash, so they are
+// shared by every emote and folder pointing at the same bytes.
+const derivedPrefix = "derived/"
+
+// DerivedBlobName returns the name of a rendition of the content with the
+// given hash: derived/ab/ab12...ef_<variant><ext>
+func DerivedBlobName(hash, variant, ext string) string {
+	return derivedPrefix + hash[:2] + "/" + hash + "_" + variant + ext
+}
+
+// HasBlob reports whether a blob exists, answering from Redis for blobs
+// already known to exist.
+func HasBlob(blobName string) (bool, error) {
+	if _, ok := knownBlob(blobName); ok {
+		return true, nil
+	}
+	_, exists, err := GetBlobMetadata(blobName)
+	if err != nil || !exists {
+		return false, err
+	}
+	rememberBlob(blobName, "1")
+	return true, nil
+}
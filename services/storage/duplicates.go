@@ -0,0 +1,149 @@
+// services/storage/duplicates.go
+package storage
+
+import (
+	"errors"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"gokeki/models"
+)
+
+// ErrNotHashed is returned when the requested emote has no perceptual hash in
+// the folder, either because it isn't stored there or because its image
+// couldn't be decoded.
+var ErrNotHashed = errors.New("emote has no perceptual hash in this folder")
+
+// hashedEmote is the representative entry of a stored emote: the hashed
+// variant with the highest scale. Hamming distances between hashes are the
+// number of differing bits.
+type hashedEmote struct {
+	entry IndexEntry
+	hash  uint64
+}
+
+// FindDuplicates groups the emotes of the folder under prefix whose perceptual
+// hashes are at most threshold bits apart. With an emote ID, it returns a
+// single cluster with that emote first followed by its matches by distance;
+// otherwise every cluster of two or more emotes, largest first. It also
+// returns how many emotes have no perceptual hash and were left out.
+func FindDuplicates(prefix, emoteID string, threshold int) ([]models.DuplicateCluster, int, error) {
+	entries, err := LoadIndex(prefix)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	byEmote := map[string]*hashedEmote{}
+	var order []string
+	unhashed := map[string]bool{}
+	for _, e := range entries {
+		if e.EmoteID == "" {
+			continue
+		}
+		hash, err := strconv.ParseUint(e.PHash, 16, 64)
+		if err != nil {
+			unhashed[e.EmoteID] = true
+			continue
+		}
+		current, ok := byEmote[e.EmoteID]
+		if !ok {
+			order = append(order, e.EmoteID)
+			byEmote[e.EmoteID] = &hashedEmote{entry: e, hash: hash}
+		} else if e.Scale > current.entry.Scale {
+			*current = hashedEmote{entry: e, hash: hash}
+		}
+	}
+	for id := range byEmote {
+		delete(unhashed, id)
+	}
+
+	emotes := make([]*hashedEmote, len(order))
+	for i, id := range order {
+		emotes[i] = byEmote[id]
+	}
+
+	if emoteID != "" {
+		target, ok := byEmote[emoteID]
+		if !ok {
+			return nil, len(unhashed), ErrNotHashed
+		}
+		return []models.DuplicateCluster{matchesOf(target, emotes, threshold)}, len(unhashed), nil
+	}
+	return clusterEmotes(emotes, threshold), len(unhashed), nil
+}
+
+// matchesOf returns target followed by the emotes within threshold of it,
+// closest first.
+func matchesOf(target *hashedEmote, emotes []*hashedEmote, threshold int) models.DuplicateCluster {
+	cluster := models.DuplicateCluster{Emotes: []models.DuplicateEntry{
+		{EmoteResponse: target.entry.EmoteResponse()},
+	}}
+	var matches []models.DuplicateEntry
+	for _, e := range emotes {
+		if e == target {
+			continue
+		}
+		if d := bits.OnesCount64(target.hash ^ e.hash); d <= threshold {
+			matches = append(matches, models.DuplicateEntry{EmoteResponse: e.entry.EmoteResponse(), Distance: d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Distance < matches[j].Distance })
+	cluster.Emotes = append(cluster.Emotes, matches...)
+	return cluster
+}
+
+// clusterEmotes links every pair of emotes within threshold and returns the
+// connected groups of two or more emotes, largest first. Distances are
+// measured against the first emote of each cluster.
+func clusterEmotes(emotes []*hashedEmote, threshold int) []models.DuplicateCluster {
+	parent := make([]int, len(emotes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range emotes {
+		for j := i + 1; j < len(emotes); j++ {
+			if bits.OnesCount64(emotes[i].hash^emotes[j].hash) <= threshold {
+				if ri, rj := find(i), find(j); ri != rj {
+					parent[rj] = ri
+				}
+			}
+		}
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := range emotes {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	clusters := []models.DuplicateCluster{}
+	for _, root := range roots {
+		members := groups[root]
+		if len(members) < 2 {
+			continue
+		}
+		first := emotes[members[0]]
+		cluster := models.DuplicateCluster{}
+		for _, m := range members {
+			cluster.Emotes = append(cluster.Emotes, models.DuplicateEntry{
+				EmoteResponse: emotes[m].entry.EmoteResponse(),
+				Distance:      bits.OnesCount64(first.hash ^ emotes[m].hash),
+			})
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return len(clusters[i].Emotes) > len(clusters[j].Emotes) })
+	return clusters
+}
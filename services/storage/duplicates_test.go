@@ -0,0 +1,85 @@
+// services/storage/duplicates_test.go
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"gokeki/models"
+)
+
+func hashed(id string, hash uint64) *hashedEmote {
+	return &hashedEmote{entry: IndexEntry{BlobName: "emote_api/" + id + ".json", EmoteID: id, EmoteName: id}, hash: hash}
+}
+
+// clusterIDs renders clusters as "a:0 b:1|c:0 d:2" for comparison.
+func clusterIDs(clusters []models.DuplicateCluster) string {
+	var parts []string
+	for _, c := range clusters {
+		var ids []string
+		for _, e := range c.Emotes {
+			ids = append(ids, e.EmoteID+":"+strconv.Itoa(e.Distance))
+		}
+		parts = append(parts, strings.Join(ids, " "))
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestClusterEmotes(t *testing.T) {
+	tests := []struct {
+		name      string
+		emotes    []*hashedEmote
+		threshold int
+		want      string
+	}{
+		{name: "no emotes", threshold: 4, want: ""},
+		{name: "identical pair", emotes: []*hashedEmote{hashed("a", 0xff), hashed("b", 0xff)}, threshold: 0, want: "a:0 b:0"},
+		{name: "outside threshold", emotes: []*hashedEmote{hashed("a", 0x00), hashed("b", 0x0f)}, threshold: 3, want: ""},
+		{name: "inside threshold", emotes: []*hashedEmote{hashed("a", 0x00), hashed("b", 0x0f)}, threshold: 4, want: "a:0 b:4"},
+		{
+			name:      "chained matches join one cluster",
+			emotes:    []*hashedEmote{hashed("a", 0x00), hashed("b", 0x03), hashed("c", 0x0f)},
+			threshold: 2,
+			want:      "a:0 b:2 c:4",
+		},
+		{
+			name:      "largest cluster first",
+			emotes:    []*hashedEmote{hashed("a", 0x00), hashed("b", 0x01), hashed("x", 0xff00), hashed("y", 0xff01), hashed("z", 0xff03), hashed("lone", 0xf0f0f0)},
+			threshold: 1,
+			want:      "x:0 y:1 z:2|a:0 b:1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clusterIDs(clusterEmotes(tt.emotes, tt.threshold)); got != tt.want {
+				t.Errorf("clusterEmotes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesOf(t *testing.T) {
+	emotes := []*hashedEmote{hashed("a", 0x00), hashed("far", 0xffff), hashed("b", 0x07), hashed("c", 0x01)}
+
+	tests := []struct {
+		name      string
+		target    int
+		threshold int
+		want      string
+	}{
+		{name: "closest first", target: 0, threshold: 3, want: "a:0 c:1 b:3"},
+		{name: "no matches", target: 1, threshold: 3, want: "far:0"},
+		{name: "zero threshold", target: 3, threshold: 0, want: "c:0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := matchesOf(emotes[tt.target], emotes, tt.threshold)
+			if got := clusterIDs([]models.DuplicateCluster{cluster}); got != tt.want {
+				t.Errorf("matchesOf = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
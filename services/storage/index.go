@@ -0,0 +1,273 @@
+// services/storage/index.go
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gokeki/config"
+	"gokeki/models"
+	"gokeki/services/cache"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// IndexEntry describes one mirrored emote of a folder, built from the blob
+// listing and its metadata.
+type IndexEntry struct {
+	BlobName     string    `json:"blob"`
+	FileName     string    `json:"fileName"`
+	ContentBlob  string    `json:"content,omitempty"`
+	EmoteID      string    `json:"emoteId"`
+	EmoteName    string    `json:"emoteName"`
+	Owner        string    `json:"owner,omitempty"`
+	Animated     bool      `json:"animated,omitempty"`
+	Scale        int       `json:"scale,omitempty"`
+	Mime         string    `json:"mime,omitempty"`
+	Width        int       `json:"width,omitempty"`
+	Height       int       `json:"height,omitempty"`
+	Preview      string    `json:"preview,omitempty"`
+	Thumbnail    string    `json:"thumbnail,omitempty"`
+	PHash        string    `json:"phash,omitempty"`
+	Frames       int       `json:"frames,omitempty"`
+	DurationMs   int       `json:"durationMs,omitempty"`
+	PlaybackMs   int       `json:"playbackMs,omitempty"`
+	LoopCount    int       `json:"loopCount,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// EntryFromBlob builds the index entry of a listed blob. References resolve to
+// the content addressed image they point at; blobs written before content
+// addressing are the image itself.
+func EntryFromBlob(b *container.BlobItem, prefix string) (IndexEntry, bool) {
+	if b.Name == nil {
+		return IndexEntry{}, false
+	}
+	fileName := strings.TrimPrefix(*b.Name, prefix)
+	if fileName == "" || strings.HasSuffix(fileName, "/") {
+		return IndexEntry{}, false
+	}
+
+	entry := IndexEntry{BlobName: *b.Name, FileName: fileName}
+	if b.Properties != nil && b.Properties.LastModified != nil {
+		entry.LastModified = *b.Properties.LastModified
+	}
+	if contentBlob, refFileName, ok := IsReference(b.Metadata); ok {
+		entry.ContentBlob = contentBlob
+		if refFileName != "" {
+			entry.FileName = refFileName
+		}
+	}
+
+	if meta, ok := ParseEmoteMetadata(b.Metadata); ok {
+		entry.EmoteID = meta.EmoteID
+		entry.EmoteName = meta.EmoteName
+		entry.Owner = meta.Owner
+		entry.Animated = meta.Animated
+		entry.Scale = meta.Scale
+		entry.Mime = meta.Mime
+		entry.Width = meta.Width
+		entry.Height = meta.Height
+		entry.Preview = meta.Preview
+		entry.Thumbnail = meta.Thumbnail
+		entry.PHash = meta.PHash
+		entry.Frames = meta.Frames
+		entry.DurationMs = meta.DurationMs
+		entry.PlaybackMs = meta.PlaybackDurationMs
+		entry.LoopCount = meta.LoopCount
+		return entry, true
+	}
+
+	// Blobs uploaded without metadata are named "<emoteId>_<variant>.<ext>"
+	stem := strings.TrimSuffix(entry.FileName, filepath.Ext(entry.FileName))
+	entry.EmoteID, _, _ = strings.Cut(stem, "_")
+	entry.EmoteName = stem
+	entry.Animated = strings.Contains(stem, "_anim")
+	return entry, true
+}
+
+// EmoteResponse converts the entry to the API representation.
+func (e IndexEntry) EmoteResponse() models.EmoteResponse {
+	folder := folderOf(e.BlobName)
+	url := PublicURL(folder, e.BlobName)
+	if e.ContentBlob != "" {
+		url = PublicURL(folder, e.ContentBlob)
+	}
+	resp := models.EmoteResponse{
+		FileName:  e.FileName,
+		URL:       url,
+		EmoteID:   e.EmoteID,
+		EmoteName: e.EmoteName,
+		Owner:     e.Owner,
+		Animated:  e.Animated,
+		Scale:     e.Scale,
+		Mime:      e.Mime,
+		Width:     e.Width,
+		Height:    e.Height,
+		PHash:     e.PHash,
+	}
+	if e.Frames > 0 {
+		resp.Animation = &models.Animation{
+			FrameCount:         e.Frames,
+			DurationMs:         e.DurationMs,
+			PlaybackDurationMs: e.PlaybackMs,
+			LoopCount:          e.LoopCount,
+		}
+	}
+	if e.Preview != "" {
+		resp.PreviewURL = PublicURL(folder, e.Preview)
+	}
+	if e.Thumbnail != "" {
+		resp.ThumbnailURL = PublicURL(folder, e.Thumbnail)
+	}
+	return resp
+}
+
+// The index of a folder is a Redis hash (blob name -> JSON entry). It is built
+// from a full listing on first use, kept up to date as references are written
+// and rebuilt after CACHE_TTL.
+func indexKey(prefix string) string {
+	return "storage_index:" + prefix
+}
+
+// LoadIndex returns the index of the folder under prefix, sorted by blob name.
+func LoadIndex(prefix string) ([]IndexEntry, error) {
+	ctx := context.Background()
+	key := indexKey(prefix)
+
+	raw, err := cache.RedisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []IndexEntry
+	if len(raw) > 0 {
+		for _, v := range raw {
+			var entry IndexEntry
+			if err := json.Unmarshal([]byte(v), &entry); err == nil {
+				entries = append(entries, entry)
+			}
+		}
+	} else {
+		if entries, err = buildIndex(prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].BlobName < entries[j].BlobName
+	})
+	return entries, nil
+}
+
+// IndexSize returns how many emotes the folder under prefix holds, according
+// to its index. The index is built first when missing.
+func IndexSize(prefix string) (int, error) {
+	n, err := cache.RedisClient.HLen(context.Background(), indexKey(prefix)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		return int(n), nil
+	}
+	entries, err := buildIndex(prefix)
+	return len(entries), err
+}
+
+func buildIndex(prefix string) ([]IndexEntry, error) {
+	blobs, err := ListBlobsWithPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []IndexEntry
+	values := map[string]interface{}{}
+	for _, b := range blobs {
+		entry, ok := EntryFromBlob(b, prefix)
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		values[entry.BlobName] = data
+	}
+
+	if len(values) > 0 {
+		ctx := context.Background()
+		key := indexKey(prefix)
+		cache.RedisClient.HSet(ctx, key, values)
+		cache.RedisClient.Expire(ctx, key, config.LoadConfig().CacheTTL)
+	}
+	return entries, nil
+}
+
+// updateIndex adds or replaces an entry in the folder index, if the index has
+// been built. A missing index is left alone so it is built complete later.
+func updateIndex(prefix string, entry IndexEntry) {
+	ctx := context.Background()
+	key := indexKey(prefix)
+	if n, err := cache.RedisClient.Exists(ctx, key).Result(); err != nil || n == 0 {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	cache.RedisClient.HSet(ctx, key, entry.BlobName, data)
+}
+
+// IndexFilter selects index entries. Zero values don't filter.
+type IndexFilter struct {
+	Query    string // Case-insensitive substring of the emote name
+	Prefix   string // Case-insensitive prefix of the emote name
+	Animated *bool
+	Mime     string
+	Since    time.Time
+	Until    time.Time
+}
+
+// Match reports whether the entry passes every filter.
+func (f IndexFilter) Match(e IndexEntry) bool {
+	name := strings.ToLower(e.EmoteName)
+	if f.Query != "" && !strings.Contains(name, strings.ToLower(f.Query)) {
+		return false
+	}
+	if f.Prefix != "" && !strings.HasPrefix(name, strings.ToLower(f.Prefix)) {
+		return false
+	}
+	if f.Animated != nil && e.Animated != *f.Animated {
+		return false
+	}
+	if f.Mime != "" && e.Mime != f.Mime && !strings.HasSuffix(e.FileName, "."+strings.TrimPrefix(f.Mime, "image/")) {
+		return false
+	}
+	if !f.Since.IsZero() && e.LastModified.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.LastModified.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// SearchIndex returns the entries of the folder under prefix matching filter.
+func SearchIndex(prefix string, filter IndexFilter) ([]IndexEntry, error) {
+	entries, err := LoadIndex(prefix)
+	if err != nil {
+		return nil, err
+	}
+	matched := entries[:0]
+	for _, e := range entries {
+		if filter.Match(e) {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
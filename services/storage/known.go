@@ -0,0 +1,75 @@
+// services/storage/known.go
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gokeki/services/cache"
+)
+
+// Blobs known to exist are remembered in Redis so repeated requests for the
+// same emotes (e.g. trending pages) don't ask Azure again. Content blobs are
+// never overwritten, so their entry only records existence; reference entries
+// hold the hash and content blob they point at and their emote metadata.
+// Entries expire after knownBlobTTL in case blobs are deleted behind our back,
+// and are dropped by POST /api/cache/clear?cache_type=storage.
+const knownBlobTTL = 24 * time.Hour
+
+func knownBlobKey(blobName string) string {
+	return "storage_known:" + blobName
+}
+
+// knownBlob returns the value remembered for a blob, if any.
+func knownBlob(blobName string) (string, bool) {
+	if cache.RedisClient == nil {
+		return "", false
+	}
+	value, err := cache.RedisClient.Get(context.Background(), knownBlobKey(blobName)).Result()
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// rememberBlob records that a blob exists, with an optional value.
+func rememberBlob(blobName, value string) {
+	if cache.RedisClient == nil {
+		return
+	}
+	cache.RedisClient.Set(context.Background(), knownBlobKey(blobName), value, knownBlobTTL)
+}
+
+// forgetBlob drops what is remembered about a blob, after it is deleted.
+func forgetBlob(blobName string) {
+	if cache.RedisClient == nil {
+		return
+	}
+	cache.RedisClient.Del(context.Background(), knownBlobKey(blobName))
+}
+
+// Perceptual hashes only depend on the image bytes, so they are remembered
+// by content hash to avoid decoding images that are already known.
+func phashKey(contentHash string) string {
+	return "storage_phash:" + contentHash
+}
+
+// KnownPHash returns the perceptual hash remembered for a content hash.
+func KnownPHash(contentHash string) (string, bool) {
+	if cache.RedisClient == nil {
+		return "", false
+	}
+	value, err := cache.RedisClient.Get(context.Background(), phashKey(contentHash)).Result()
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// RememberPHash records the perceptual hash of a content hash.
+func RememberPHash(contentHash, phash string) {
+	if cache.RedisClient == nil {
+		return
+	}
+	cache.RedisClient.Set(context.Background(), phashKey(contentHash), phash, knownBlobTTL)
+}
@@ -0,0 +1,122 @@
+// services/storage/listing.go
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"gokeki/config"
+	"gokeki/services/cache"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// ListBlobsPage lists a single page of at most maxResults blobs under prefix,
+// starting at marker (empty for the first page). The returned marker is
+// empty when there are no more pages.
+func ListBlobsPage(prefix, marker string, maxResults int) ([]*container.BlobItem, string, error) {
+	if !AzureStorageAvailable() {
+		return nil, "", nil
+	}
+
+	opts := &azblob.ListBlobsFlatOptions{
+		Prefix:     to.Ptr(prefix),
+		MaxResults: to.Ptr(int32(maxResults)),
+		Include:    container.ListBlobsInclude{Metadata: true},
+	}
+	if marker != "" {
+		opts.Marker = to.Ptr(marker)
+	}
+
+	pager := serviceClient.NewListBlobsFlatPager(config.LoadConfig().ContainerName, opts)
+	resp, err := pager.NextPage(context.Background())
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if resp.NextMarker != nil {
+		next = *resp.NextMarker
+	}
+	return resp.Segment.BlobItems, next, nil
+}
+
+// EncodeCursor turns a continuation marker into the opaque cursor handed to
+// clients.
+func EncodeCursor(marker string) string {
+	if marker == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(marker))
+}
+
+// DecodeCursor reverses EncodeCursor.
+func DecodeCursor(cursor string) (string, error) {
+	marker, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(marker) == 0 {
+		return "", fmt.Errorf("invalid cursor")
+	}
+	return string(marker), nil
+}
+
+func pageIndexKey(prefix string, limit int) string {
+	return fmt.Sprintf("storage_pages:%s:%d", prefix, limit)
+}
+
+// PageMarker returns the continuation marker that starts page (1-based) when
+// prefix is listed limit blobs at a time. Markers are remembered in Redis as
+// pages are walked, so reaching a page only lists the pages never seen before
+// instead of the whole prefix. found is false when the page doesn't exist.
+func PageMarker(prefix string, limit, page int) (marker string, found bool, err error) {
+	if page <= 1 {
+		return "", true, nil
+	}
+
+	ctx := context.Background()
+	key := pageIndexKey(prefix, limit)
+	known, err := cache.RedisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return "", false, err
+	}
+
+	// Start from the closest page already indexed
+	current := 1
+	for p, m := range known {
+		n, _ := strconv.Atoi(p)
+		if n > current && n <= page {
+			current, marker = n, m
+		}
+	}
+
+	for current < page {
+		_, next, err := ListBlobsPage(prefix, marker, limit)
+		if err != nil {
+			return "", false, err
+		}
+		if next == "" {
+			return "", false, nil
+		}
+		current++
+		marker = next
+		cache.RedisClient.HSet(ctx, key, strconv.Itoa(current), marker)
+	}
+	cache.RedisClient.Expire(ctx, key, config.LoadConfig().CacheTTL)
+
+	return marker, true, nil
+}
+
+// RememberPageMarker records the marker of the page following page, learnt
+// while serving it.
+func RememberPageMarker(prefix string, limit, page int, next string) {
+	if next == "" {
+		return
+	}
+	ctx := context.Background()
+	key := pageIndexKey(prefix, limit)
+	cache.RedisClient.HSet(ctx, key, strconv.Itoa(page+1), next)
+	cache.RedisClient.Expire(ctx, key, config.LoadConfig().CacheTTL)
+}
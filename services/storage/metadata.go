@@ -0,0 +1,157 @@
+// services/storage/metadata.go
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"gokeki/models"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+)
+
+// Emote metadata keys. Azure metadata keys must be valid C# identifiers and
+// values plain ASCII, so free text values (names) are URL encoded.
+const (
+	metaEmoteID   = "emoteid"
+	metaEmoteName = "emotename"
+	metaOwner     = "owner"
+	metaAnimated  = "animated"
+	metaScale     = "scale"
+	metaMime      = "mime"
+	metaFolder    = "folder"
+	metaWidth     = "width"
+	metaHeight    = "height"
+	metaPreview   = "preview"
+	metaThumbnail = "thumbnail"
+	metaPHash     = "phash"
+	metaFrames    = "frames"
+	metaDuration  = "duration"
+	metaPlayback  = "playback"
+	metaLoops     = "loops"
+)
+
+// EmoteMetadata is the emote information written as blob metadata on mirrored
+// images and their references, so listings don't have to guess it from names.
+type EmoteMetadata struct {
+	EmoteID   string
+	EmoteName string
+	Owner     string
+	Animated  bool
+	Scale     int
+	Mime      string
+	Folder    string
+	Width     int
+	Height    int
+
+	// Blob names of the first frame preview and the thumbnail, if any
+	Preview   string
+	Thumbnail string
+
+	// Perceptual hash (dHash) of the first frame, 16 hex digits
+	PHash string
+
+	// Frame count, encoded and playback durations (ms) and loop count of
+	// animated images. The per-frame delays don't fit in blob metadata and are
+	// only returned when mirroring.
+	Frames             int
+	DurationMs         int
+	PlaybackDurationMs int
+	LoopCount          int
+}
+
+// Animation returns the stored animation details, or nil for static images
+// and images mirrored before they were recorded.
+func (m EmoteMetadata) Animation() *models.Animation {
+	if m.Frames == 0 {
+		return nil
+	}
+	return &models.Animation{
+		FrameCount:         m.Frames,
+		DurationMs:         m.DurationMs,
+		PlaybackDurationMs: m.PlaybackDurationMs,
+		LoopCount:          m.LoopCount,
+	}
+}
+
+// toMap encodes the metadata for an upload, merged into base when given.
+func (m EmoteMetadata) toMap(base map[string]*string) map[string]*string {
+	md := map[string]*string{}
+	for k, v := range base {
+		md[k] = v
+	}
+	md[metaEmoteID] = to.Ptr(m.EmoteID)
+	md[metaEmoteName] = to.Ptr(url.QueryEscape(m.EmoteName))
+	md[metaOwner] = to.Ptr(url.QueryEscape(m.Owner))
+	md[metaAnimated] = to.Ptr(strconv.FormatBool(m.Animated))
+	md[metaScale] = to.Ptr(strconv.Itoa(m.Scale))
+	md[metaMime] = to.Ptr(m.Mime)
+	md[metaFolder] = to.Ptr(m.Folder)
+	if m.Width > 0 && m.Height > 0 {
+		md[metaWidth] = to.Ptr(strconv.Itoa(m.Width))
+		md[metaHeight] = to.Ptr(strconv.Itoa(m.Height))
+	}
+	if m.Preview != "" {
+		md[metaPreview] = to.Ptr(m.Preview)
+	}
+	if m.Thumbnail != "" {
+		md[metaThumbnail] = to.Ptr(m.Thumbnail)
+	}
+	if m.PHash != "" {
+		md[metaPHash] = to.Ptr(m.PHash)
+	}
+	if m.Frames > 0 {
+		md[metaFrames] = to.Ptr(strconv.Itoa(m.Frames))
+		md[metaDuration] = to.Ptr(strconv.Itoa(m.DurationMs))
+		md[metaPlayback] = to.Ptr(strconv.Itoa(m.PlaybackDurationMs))
+		md[metaLoops] = to.Ptr(strconv.Itoa(m.LoopCount))
+	}
+	return md
+}
+
+// digest identifies the encoded metadata, so a stored reference is only
+// rewritten when some of it changed (a rename, a new thumbnail, a perceptual
+// hash or animation timings computed after it was first written).
+func (m EmoteMetadata) digest() string {
+	md := m.toMap(nil)
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\n", k, *md[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// ParseEmoteMetadata decodes emote metadata from a listed or fetched blob.
+// The boolean is false for blobs uploaded without emote metadata.
+func ParseEmoteMetadata(metadata map[string]*string) (EmoteMetadata, bool) {
+	m := EmoteMetadata{EmoteID: MetadataValue(metadata, metaEmoteID)}
+	if m.EmoteID == "" {
+		return m, false
+	}
+	m.EmoteName, _ = url.QueryUnescape(MetadataValue(metadata, metaEmoteName))
+	m.Owner, _ = url.QueryUnescape(MetadataValue(metadata, metaOwner))
+	m.Animated, _ = strconv.ParseBool(MetadataValue(metadata, metaAnimated))
+	m.Scale, _ = strconv.Atoi(MetadataValue(metadata, metaScale))
+	m.Mime = MetadataValue(metadata, metaMime)
+	m.Folder = MetadataValue(metadata, metaFolder)
+	m.Width, _ = strconv.Atoi(MetadataValue(metadata, metaWidth))
+	m.Height, _ = strconv.Atoi(MetadataValue(metadata, metaHeight))
+	m.Preview = MetadataValue(metadata, metaPreview)
+	m.Thumbnail = MetadataValue(metadata, metaThumbnail)
+	m.PHash = MetadataValue(metadata, metaPHash)
+	m.Frames, _ = strconv.Atoi(MetadataValue(metadata, metaFrames))
+	m.DurationMs, _ = strconv.Atoi(MetadataValue(metadata, metaDuration))
+	m.PlaybackDurationMs, _ = strconv.Atoi(MetadataValue(metadata, metaPlayback))
+	m.LoopCount, _ = strconv.Atoi(MetadataValue(metadata, metaLoops))
+	return m, true
+}
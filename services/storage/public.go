@@ -0,0 +1,92 @@
+// services/storage/public.go
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gokeki/config"
+)
+
+var purgeClient = &http.Client{Timeout: 10 * time.Second}
+
+// PublicURL returns the URL clients should use for a blob referenced from
+// folder: on the folder's PUBLIC_BASE_URL_OVERRIDES entry, else on
+// PUBLIC_BASE_URL, else directly on the container.
+func PublicURL(folder, blobName string) string {
+	cfg := config.LoadConfig()
+	base := cfg.PublicBaseURLs[folder]
+	if base == "" {
+		base = cfg.PublicBaseURL
+	}
+	if base == "" {
+		return BlobURL(blobName)
+	}
+	return base + "/" + blobName
+}
+
+// folderOf returns the top level folder of a blob name.
+func folderOf(blobName string) string {
+	folder, _, _ := strings.Cut(blobName, "/")
+	return folder
+}
+
+// purgeCDN asks CDN_PURGE_URL to drop cached copies of blobs whose content
+// changed. The webhook receives a JSON body with the public URLs and blob
+// paths, and the CDN_PURGE_TOKEN as a bearer token. It runs in the
+// background; failures are only logged since the CDN copy expires on its own.
+func purgeCDN(folder string, blobNames ...string) {
+	cfg := config.LoadConfig()
+	if cfg.CDNPurgeURL == "" || len(blobNames) == 0 {
+		return
+	}
+
+	payload := struct {
+		URLs  []string `json:"urls"`
+		Paths []string `json:"paths"`
+	}{}
+	for _, name := range blobNames {
+		payload.URLs = append(payload.URLs, PublicURL(folder, name))
+		payload.Paths = append(payload.Paths, "/"+name)
+	}
+
+	go func() {
+		if err := postPurge(cfg, payload); err != nil {
+			log.Printf("❌ CDN purge failed for %v: %v", blobNames, err)
+			return
+		}
+		log.Printf("🧹 CDN purge requested for %v", blobNames)
+	}()
+}
+
+func postPurge(cfg *config.Config, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", cfg.CDNPurgeURL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.CDNPurgeToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.CDNPurgeToken)
+	}
+
+	resp, err := purgeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("purge webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
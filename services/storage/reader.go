@@ -0,0 +1,110 @@
+// services/storage/reader.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"gokeki/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// BlobReader reads a blob through ranged downloads. It is an io.ReadSeeker,
+// so http.ServeContent can answer range and conditional requests; nothing is
+// downloaded until the first Read, and only from the requested offset.
+type BlobReader struct {
+	BlobInfo
+	Size int64
+
+	name   string
+	offset int64
+	body   io.ReadCloser
+}
+
+// OpenBlob returns a reader for a blob. The boolean is false when the blob
+// does not exist.
+func OpenBlob(blobName string) (*BlobReader, bool, error) {
+	if !AzureStorageAvailable() {
+		return nil, false, nil
+	}
+
+	props, err := serviceClient.ServiceClient().
+		NewContainerClient(config.LoadConfig().ContainerName).
+		NewBlobClient(blobName).
+		GetProperties(context.Background(), nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		forgetBlob(blobName)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	r := &BlobReader{name: blobName}
+	if props.ContentLength != nil {
+		r.Size = *props.ContentLength
+	}
+	if props.ContentType != nil {
+		r.ContentType = *props.ContentType
+	}
+	if props.ETag != nil {
+		r.ETag = string(*props.ETag)
+	}
+	if props.LastModified != nil {
+		r.LastModified = *props.LastModified
+	}
+	return r, true, nil
+}
+
+// Read streams the blob from the current offset to its end, opening the
+// download on first use and after every seek.
+func (r *BlobReader) Read(p []byte) (int, error) {
+	if r.offset >= r.Size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		resp, err := serviceClient.DownloadStream(context.Background(), config.LoadConfig().ContainerName, r.name, &azblob.DownloadStreamOptions{
+			Range: blob.HTTPRange{Offset: r.offset},
+		})
+		if err != nil {
+			return 0, err
+		}
+		r.body = resp.Body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek moves the offset. The open download, if any, is dropped unless the
+// offset is unchanged.
+func (r *BlobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.Size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative blob offset")
+	}
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// Close releases the open download.
+func (r *BlobReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,134 @@
+// services/storage/sas.go
+package storage
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gokeki/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+)
+
+// SAS modes for the URLs handed out to clients.
+const (
+	SASModeNone    = ""        // Public container, plain URLs
+	SASModeBlob    = "blob"    // One service SAS per blob
+	SASModeAccount = "account" // One account SAS shared by every URL
+)
+
+var (
+	accountSASMu     sync.Mutex
+	accountSASToken  string
+	accountSASExpiry time.Time
+)
+
+// sasExpiry returns the expiry for a SAS generated now. Expiries are aligned
+// to half the configured lifetime so URLs stay identical (and cacheable by
+// browsers and CDNs) for a while, and are always valid for at least half the
+// lifetime.
+func sasExpiry(cfg *config.Config) time.Time {
+	half := cfg.SASExpiry / 2
+	if half < time.Minute {
+		half = time.Minute
+	}
+	return time.Now().UTC().Truncate(half).Add(cfg.SASExpiry)
+}
+
+// blobPermissions parses a permission string such as "r" or "rw".
+func blobPermissions(s string) sas.BlobPermissions {
+	return sas.BlobPermissions{
+		Read:   strings.Contains(s, "r"),
+		Add:    strings.Contains(s, "a"),
+		Create: strings.Contains(s, "c"),
+		Write:  strings.Contains(s, "w"),
+		Delete: strings.Contains(s, "d"),
+		List:   strings.Contains(s, "l"),
+	}
+}
+
+func accountPermissions(s string) sas.AccountPermissions {
+	return sas.AccountPermissions{
+		Read:   strings.Contains(s, "r"),
+		Add:    strings.Contains(s, "a"),
+		Create: strings.Contains(s, "c"),
+		Write:  strings.Contains(s, "w"),
+		Delete: strings.Contains(s, "d"),
+		List:   strings.Contains(s, "l"),
+	}
+}
+
+// SignedURL returns the URL clients should use to read a blob: the plain blob
+// URL for public containers, or the blob URL with a SAS token when
+// STORAGE_SAS_MODE is set. Signing failures (e.g. a connection string without
+// an account key) are logged and fall back to the plain URL.
+func SignedURL(blobName string) string {
+	plain := BlobURL(blobName)
+	if !AzureStorageAvailable() {
+		return plain
+	}
+
+	cfg := config.LoadConfig()
+	switch cfg.SASMode {
+	case SASModeBlob:
+		blobClient := serviceClient.ServiceClient().
+			NewContainerClient(cfg.ContainerName).
+			NewBlobClient(blobName)
+		signed, err := blobClient.GetSASURL(blobPermissions(cfg.SASPermissions), sasExpiry(cfg), nil)
+		if err != nil {
+			log.Printf("❌ Failed to sign URL for %s: %v", blobName, err)
+			return plain
+		}
+		if _, query, ok := strings.Cut(signed, "?"); ok {
+			return plain + "?" + query
+		}
+		return plain
+	case SASModeAccount:
+		if token := accountSAS(cfg); token != "" {
+			return plain + "?" + token
+		}
+		return plain
+	default:
+		return plain
+	}
+}
+
+// accountSAS returns the shared account SAS token, regenerating it once half
+// of its lifetime has passed.
+func accountSAS(cfg *config.Config) string {
+	accountSASMu.Lock()
+	defer accountSASMu.Unlock()
+
+	if accountSASToken != "" && time.Until(accountSASExpiry) > cfg.SASExpiry/2 {
+		return accountSASToken
+	}
+
+	expiry := sasExpiry(cfg)
+	signed, err := serviceClient.ServiceClient().GetSASURL(
+		sas.AccountResourceTypes{Object: true},
+		accountPermissions(cfg.SASPermissions),
+		expiry,
+		nil,
+	)
+	if err != nil {
+		log.Printf("❌ Failed to create account SAS: %v", err)
+		return ""
+	}
+	_, accountSASToken, _ = strings.Cut(signed, "?")
+	accountSASExpiry = expiry
+	return accountSASToken
+}
+
+// PresentURL prepares a stored URL for a client. URLs of our container are
+// re-signed, since cached responses keep the unsigned URL; other URLs are
+// returned unchanged.
+func PresentURL(rawURL string) string {
+	prefix := ContainerURL() + "/"
+	if !strings.HasPrefix(rawURL, prefix) {
+		return rawURL
+	}
+	blobName, _, _ := strings.Cut(strings.TrimPrefix(rawURL, prefix), "?")
+	return SignedURL(blobName)
+}
@@ -2,13 +2,17 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"log"
 	"strings"
 	"sync"
+	"time"
 
 	"gokeki/config"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
@@ -69,38 +73,140 @@ func ContainerURL() string {
 	return "https://" + accountName + ".blob.core.windows.net/" + config.LoadConfig().ContainerName
 }
 
-func UploadToAzureBlob(fileData []byte, blobName string, contentType string) (string, error) {
+// BlobURL returns the URL of a blob in the configured container.
+func BlobURL(blobName string) string {
+	return ContainerURL() + "/" + blobName
+}
+
+// UploadToAzureBlob uploads the blob unless it already exists, setting the
+// given metadata (may be nil). Existence is checked with the blob properties
+// first so known images aren't sent again. The upload itself is conditional
+// (If-None-Match: *), so concurrent uploads of the same blob can't overwrite
+// each other, and the losing side sees the blob as already existing.
+func UploadToAzureBlob(fileData []byte, blobName string, contentType string, metadata map[string]*string) (string, error) {
+	return UploadStreamToAzureBlob(bytes.NewReader(fileData), blobName, contentType, metadata)
+}
+
+// UploadStreamToAzureBlob is UploadToAzureBlob for a body read from r.
+func UploadStreamToAzureBlob(r io.Reader, blobName string, contentType string, metadata map[string]*string) (string, error) {
 	if !AzureStorageAvailable() {
 		return "", nil
 	}
 
-	cfg := config.LoadConfig()
-
-	// Check existence with a head request (download with range 0-0)
-	_, err := serviceClient.DownloadStream(context.Background(), cfg.ContainerName, blobName, &azblob.DownloadStreamOptions{
-		Range: azblob.HTTPRange{Count: 1},
-	})
-	if err == nil {
-		url := "https://" + accountName + ".blob.core.windows.net/" + cfg.ContainerName + "/" + blobName
-		return url, nil // Already exists
+	if _, ok := knownBlob(blobName); ok {
+		return BlobURL(blobName), nil
 	}
-
-	if !bloberror.HasCode(err, bloberror.BlobNotFound) {
-		return "", err
+	if _, exists, err := GetBlobMetadata(blobName); err == nil && exists {
+		rememberBlob(blobName, "1")
+		return BlobURL(blobName), nil
 	}
 
-	// Upload if not exists
-	_, err = serviceClient.UploadBuffer(context.Background(), cfg.ContainerName, blobName, fileData, &azblob.UploadBufferOptions{
+	_, err := serviceClient.UploadStream(context.Background(), config.LoadConfig().ContainerName, blobName, r, &azblob.UploadStreamOptions{
 		HTTPHeaders: &blob.HTTPHeaders{
 			BlobContentType: to.Ptr(contentType),
 		},
+		Metadata: metadata,
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+		},
 	})
-	if err != nil {
+	if err != nil && !alreadyExists(err) {
 		return "", err
 	}
 
-	url := "https://" + accountName + ".blob.core.windows.net/" + cfg.ContainerName + "/" + blobName
-	return url, nil
+	rememberBlob(blobName, "1")
+	return BlobURL(blobName), nil
+}
+
+// alreadyExists reports whether a conditional upload failed because the blob
+// exists (409 BlobAlreadyExists or 412 ConditionNotMet).
+func alreadyExists(err error) bool {
+	return bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet)
+}
+
+// BlobInfo holds the properties of a downloaded blob.
+type BlobInfo struct {
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// DownloadBlob downloads a whole blob. The boolean is false when the blob does
+// not exist.
+func DownloadBlob(blobName string) ([]byte, BlobInfo, bool, error) {
+	var info BlobInfo
+	if !AzureStorageAvailable() {
+		return nil, info, false, nil
+	}
+
+	resp, err := serviceClient.DownloadStream(context.Background(), config.LoadConfig().ContainerName, blobName, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, info, false, nil
+	}
+	if err != nil {
+		return nil, info, false, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, info, false, err
+	}
+	if resp.ContentType != nil {
+		info.ContentType = *resp.ContentType
+	}
+	if resp.ETag != nil {
+		info.ETag = string(*resp.ETag)
+	}
+	if resp.LastModified != nil {
+		info.LastModified = *resp.LastModified
+	}
+	return data, info, true, nil
+}
+
+// DeleteBlob deletes a blob. Deleting a missing blob is not an error.
+func DeleteBlob(blobName string) error {
+	if !AzureStorageAvailable() {
+		return nil
+	}
+
+	forgetBlob(blobName)
+	_, err := serviceClient.DeleteBlob(context.Background(), config.LoadConfig().ContainerName, blobName, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return err
+	}
+	return nil
+}
+
+// GetBlobMetadata returns the metadata of a blob. The boolean is false when
+// the blob does not exist.
+func GetBlobMetadata(blobName string) (map[string]*string, bool, error) {
+	if !AzureStorageAvailable() {
+		return nil, false, nil
+	}
+
+	blobClient := serviceClient.ServiceClient().
+		NewContainerClient(config.LoadConfig().ContainerName).
+		NewBlobClient(blobName)
+	props, err := blobClient.GetProperties(context.Background(), nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return props.Metadata, true, nil
+}
+
+// MetadataValue looks up a metadata key case-insensitively. Keys come back
+// canonicalized as HTTP headers (e.g. "Sha256") when read from properties.
+func MetadataValue(metadata map[string]*string, key string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) && v != nil {
+			return *v
+		}
+	}
+	return ""
 }
 
 func ListBlobsWithPrefix(prefix string) ([]*container.BlobItem, error) {
@@ -110,7 +216,8 @@ func ListBlobsWithPrefix(prefix string) ([]*container.BlobItem, error) {
 
 	var blobs []*container.BlobItem
 	pager := serviceClient.NewListBlobsFlatPager(config.LoadConfig().ContainerName, &azblob.ListBlobsFlatOptions{
-		Prefix: to.Ptr(prefix),
+		Prefix:  to.Ptr(prefix),
+		Include: container.ListBlobsInclude{Metadata: true},
 	})
 
 	for pager.More() {